@@ -0,0 +1,446 @@
+// Package backendpool load-balances the gateway across multiple Ollama
+// backend-proxy instances, with per-backend health checks, a circuit
+// breaker, and sticky routing so a job's polls/streams always land on the
+// backend that created it.
+package backendpool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Strategy selects which backend serves a new job.
+type Strategy string
+
+const (
+	RoundRobin     Strategy = "round_robin"
+	LeastInFlight  Strategy = "least_in_flight"
+	WeightedRandom Strategy = "weighted_random"
+)
+
+// Backend is one upstream backend-proxy instance in the pool.
+type Backend struct {
+	URL    string
+	Weight int
+}
+
+// circuitState is the classic closed/open/half-open circuit breaker state
+// machine, evaluated per backend from consecutive failures.
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// entry tracks live state for one backend: in-flight count for the
+// least-in-flight strategy, and circuit breaker bookkeeping.
+type entry struct {
+	backend Backend
+
+	inFlight int64 // atomic
+
+	mu               sync.Mutex
+	state            circuitState
+	consecutiveFails int
+	openedAt         time.Time
+}
+
+func (e *entry) available() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	switch e.state {
+	case circuitClosed:
+		return true
+	case circuitHalfOpen:
+		return true // the trial request is allowed through
+	default: // circuitOpen
+		return false
+	}
+}
+
+// maybeHalfOpen transitions an open circuit to half-open once the cooldown
+// has elapsed, so Pick can try it again.
+func (e *entry) maybeHalfOpen(cooldown time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.state == circuitOpen && time.Since(e.openedAt) >= cooldown {
+		e.state = circuitHalfOpen
+	}
+}
+
+func (e *entry) reportResult(success bool, failureThreshold int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if success {
+		e.consecutiveFails = 0
+		e.state = circuitClosed
+		return
+	}
+	e.consecutiveFails++
+	if e.state == circuitHalfOpen || e.consecutiveFails >= failureThreshold {
+		e.state = circuitOpen
+		e.openedAt = time.Now()
+	}
+}
+
+// Pool load-balances across a fixed set of backends.
+type Pool struct {
+	strategy         Strategy
+	healthPath       string
+	failureThreshold int
+	cooldown         time.Duration
+	client           *http.Client
+
+	entries []*entry
+	rrNext  uint64 // atomic
+
+	mu      sync.Mutex
+	sticky  map[string]*stickyBinding
+	stopCh  chan struct{}
+	stopped bool
+}
+
+// stickyBinding pins a job to a backend while tracking how many callers are
+// relying on that pin, so a coalesced job shared by several concurrent
+// callers (see jobGroup) isn't unbound until the last of them is done with
+// it.
+type stickyBinding struct {
+	backend *entry
+	refs    int
+}
+
+// NewPool builds a Pool. failureThreshold is the number of consecutive
+// failures that trips a backend's circuit open; cooldown is how long it
+// stays open before a half-open trial request is allowed.
+func NewPool(backends []Backend, strategy Strategy, healthPath string, failureThreshold int, cooldown time.Duration) (*Pool, error) {
+	if len(backends) == 0 {
+		return nil, fmt.Errorf("backendpool: at least one backend is required")
+	}
+	if healthPath == "" {
+		healthPath = "/stats"
+	}
+	if failureThreshold <= 0 {
+		failureThreshold = 3
+	}
+
+	entries := make([]*entry, len(backends))
+	for i, b := range backends {
+		if b.Weight <= 0 {
+			b.Weight = 1
+		}
+		entries[i] = &entry{backend: b}
+	}
+
+	return &Pool{
+		strategy:         strategy,
+		healthPath:       healthPath,
+		failureThreshold: failureThreshold,
+		cooldown:         cooldown,
+		client:           &http.Client{Timeout: 5 * time.Second},
+		entries:          entries,
+		sticky:           make(map[string]*stickyBinding),
+		stopCh:           make(chan struct{}),
+	}, nil
+}
+
+// Start launches the background health-probe loop. Call Stop to shut it
+// down.
+func (p *Pool) Start(interval time.Duration) {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.probeAll()
+			case <-p.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the background health-probe loop.
+func (p *Pool) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.stopped {
+		p.stopped = true
+		close(p.stopCh)
+	}
+}
+
+func (p *Pool) probeAll() {
+	for _, e := range p.entries {
+		e.maybeHalfOpen(p.cooldown)
+		go func(e *entry) {
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			req, err := http.NewRequestWithContext(ctx, "GET", e.backend.URL+p.healthPath, nil)
+			if err != nil {
+				e.reportResult(false, p.failureThreshold)
+				return
+			}
+			resp, err := p.client.Do(req)
+			if err != nil {
+				e.reportResult(false, p.failureThreshold)
+				return
+			}
+			defer resp.Body.Close()
+			e.reportResult(resp.StatusCode == http.StatusOK, p.failureThreshold)
+		}(e)
+	}
+}
+
+// ErrNoHealthyBackend is returned by Pick when every backend's circuit is
+// open.
+var ErrNoHealthyBackend = fmt.Errorf("backendpool: no healthy backend available")
+
+// Pick selects a backend for a new job according to the pool's strategy,
+// skipping any whose circuit is open.
+func (p *Pool) Pick() (Backend, error) {
+	return p.PickFrom(nil)
+}
+
+// PickFrom is like Pick but, when allowed is non-empty, only considers
+// backends whose URL is in allowed (e.g. the set of backends the model
+// registry says can serve the requested model).
+func (p *Pool) PickFrom(allowed []string) (Backend, error) {
+	var allowedSet map[string]bool
+	if len(allowed) > 0 {
+		allowedSet = make(map[string]bool, len(allowed))
+		for _, url := range allowed {
+			allowedSet[url] = true
+		}
+	}
+
+	candidates := make([]*entry, 0, len(p.entries))
+	for _, e := range p.entries {
+		if allowedSet != nil && !allowedSet[e.backend.URL] {
+			continue
+		}
+		e.maybeHalfOpen(p.cooldown)
+		if e.available() {
+			candidates = append(candidates, e)
+		}
+	}
+	if len(candidates) == 0 {
+		return Backend{}, ErrNoHealthyBackend
+	}
+
+	switch p.strategy {
+	case LeastInFlight:
+		best := candidates[0]
+		for _, e := range candidates[1:] {
+			if atomic.LoadInt64(&e.inFlight) < atomic.LoadInt64(&best.inFlight) {
+				best = e
+			}
+		}
+		return best.backend, nil
+	case WeightedRandom:
+		total := 0
+		for _, e := range candidates {
+			total += e.backend.Weight
+		}
+		r := rand.Intn(total)
+		for _, e := range candidates {
+			r -= e.backend.Weight
+			if r < 0 {
+				return e.backend, nil
+			}
+		}
+		return candidates[len(candidates)-1].backend, nil
+	default: // RoundRobin
+		n := atomic.AddUint64(&p.rrNext, 1)
+		return candidates[int(n-1)%len(candidates)].backend, nil
+	}
+}
+
+// BackendURLs returns the URL of every backend in the pool, regardless of
+// circuit state, for callers (like the model registry) that need to poll
+// every configured backend rather than just the currently healthy ones.
+func (p *Pool) BackendURLs() []string {
+	urls := make([]string, len(p.entries))
+	for i, e := range p.entries {
+		urls[i] = e.backend.URL
+	}
+	return urls
+}
+
+func (p *Pool) entryFor(url string) *entry {
+	for _, e := range p.entries {
+		if e.backend.URL == url {
+			return e
+		}
+	}
+	return nil
+}
+
+// BindJob pins jobID to the backend that created it, so later
+// polls/streams for that job always reach the same instance. It does not
+// count as an attached caller on its own; the caller that created the job
+// (and any caller coalesced onto it via jobGroup) must each call AttachJob
+// and later UnbindJob so the binding outlives every one of them.
+func (p *Pool) BindJob(jobID, backendURL string) {
+	e := p.entryFor(backendURL)
+	if e == nil {
+		return
+	}
+	p.mu.Lock()
+	p.sticky[jobID] = &stickyBinding{backend: e}
+	p.mu.Unlock()
+}
+
+// AttachJob records one more caller relying on jobID's sticky binding. Call
+// once per caller that will later call UnbindJob, including the caller that
+// originally created the job — createBackendJob does this for every caller
+// it hands jobID back to, whether freshly created or coalesced.
+func (p *Pool) AttachJob(jobID string) {
+	p.mu.Lock()
+	if b, ok := p.sticky[jobID]; ok {
+		b.refs++
+	}
+	p.mu.Unlock()
+}
+
+// UnbindJob releases one caller's attachment to jobID's sticky binding,
+// dropping the mapping once every attached caller (see AttachJob) has
+// released it.
+func (p *Pool) UnbindJob(jobID string) {
+	p.mu.Lock()
+	if b, ok := p.sticky[jobID]; ok {
+		b.refs--
+		if b.refs <= 0 {
+			delete(p.sticky, jobID)
+		}
+	}
+	p.mu.Unlock()
+}
+
+// ErrJobNotBound is returned by BackendForJob when no sticky mapping exists
+// (the gateway process restarted, or the job ID is unknown).
+var ErrJobNotBound = fmt.Errorf("backendpool: job has no bound backend")
+
+// BackendForJob returns the backend a job was pinned to.
+func (p *Pool) BackendForJob(jobID string) (Backend, error) {
+	p.mu.Lock()
+	b, ok := p.sticky[jobID]
+	p.mu.Unlock()
+	if !ok {
+		return Backend{}, ErrJobNotBound
+	}
+	return b.backend.backend, nil
+}
+
+// Acquire marks a job as in-flight against a backend (for the
+// least-in-flight strategy); the returned func must be called when the
+// request finishes.
+func (p *Pool) Acquire(backendURL string) func() {
+	e := p.entryFor(backendURL)
+	if e == nil {
+		return func() {}
+	}
+	atomic.AddInt64(&e.inFlight, 1)
+	released := false
+	return func() {
+		if released {
+			return
+		}
+		released = true
+		atomic.AddInt64(&e.inFlight, -1)
+	}
+}
+
+// ReportResult feeds the circuit breaker for a backend.
+func (p *Pool) ReportResult(backendURL string, success bool) {
+	if e := p.entryFor(backendURL); e != nil {
+		e.reportResult(success, p.failureThreshold)
+	}
+}
+
+// AggregateStats fetches /stats from every backend and merges numeric
+// fields by summation, returning the merged document alongside the raw
+// per-backend responses.
+func (p *Pool) AggregateStats(ctx context.Context) (map[string]interface{}, error) {
+	type result struct {
+		url  string
+		data map[string]interface{}
+		err  error
+	}
+
+	results := make(chan result, len(p.entries))
+	for _, e := range p.entries {
+		go func(e *entry) {
+			req, err := http.NewRequestWithContext(ctx, "GET", e.backend.URL+"/stats", nil)
+			if err != nil {
+				results <- result{url: e.backend.URL, err: err}
+				return
+			}
+			resp, err := p.client.Do(req)
+			if err != nil {
+				results <- result{url: e.backend.URL, err: err}
+				return
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			var data map[string]interface{}
+			if err := json.Unmarshal(body, &data); err != nil {
+				results <- result{url: e.backend.URL, err: err}
+				return
+			}
+			results <- result{url: e.backend.URL, data: data}
+		}(e)
+	}
+
+	merged := make(map[string]interface{})
+	perBackend := make(map[string]interface{})
+	var firstErr error
+	for range p.entries {
+		r := <-results
+		if r.err != nil {
+			perBackend[r.url] = map[string]interface{}{"error": r.err.Error()}
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			continue
+		}
+		perBackend[r.url] = r.data
+		for k, v := range r.data {
+			if n, ok := toFloat(v); ok {
+				if existing, ok := toFloat(merged[k]); ok {
+					merged[k] = existing + n
+				} else {
+					merged[k] = n
+				}
+			}
+		}
+	}
+	merged["backends"] = perBackend
+	if len(perBackend) == 0 {
+		return nil, firstErr
+	}
+	return merged, nil
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,175 @@
+// Package cache implements an optional response cache for the gateway,
+// keyed by a hash of the request fields that affect its output
+// (normalized messages, model, temperature, top_p, max_tokens), with
+// pluggable in-memory and Redis-backed stores.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Chunk is one recorded delta, replayed verbatim on a streaming cache hit.
+type Chunk struct {
+	Delta        string
+	Done         bool
+	FinishReason string
+}
+
+// Entry is a cached chat completion: enough to satisfy both a
+// non-streaming request (Content/FinishReason) and a streaming one
+// (Chunks, replayed in order with configurable pacing).
+type Entry struct {
+	Content      string
+	FinishReason string
+	Chunks       []Chunk
+}
+
+// Store persists Entry values under a cache key with a TTL.
+type Store interface {
+	Get(ctx context.Context, key string) (*Entry, bool, error)
+	Set(ctx context.Context, key string, entry *Entry, ttl time.Duration) error
+}
+
+// keySource is the canonical, normalized shape hashed into a cache key.
+// Messages is a map[string]interface{} slice; encoding/json sorts map keys
+// on marshal, so two semantically identical requests built with different
+// field orderings still hash the same.
+type keySource struct {
+	Model       string                   `json:"model"`
+	Messages    []map[string]interface{} `json:"messages"`
+	Temperature float64                  `json:"temperature"`
+	TopP        float64                  `json:"top_p"`
+	MaxTokens   int                      `json:"max_tokens"`
+}
+
+// Key hashes the semantically relevant fields of a chat request into a
+// stable cache key.
+func Key(model string, messages []map[string]interface{}, temperature, topP float64, maxTokens int) string {
+	data, _ := json.Marshal(keySource{
+		Model:       model,
+		Messages:    messages,
+		Temperature: temperature,
+		TopP:        topP,
+		MaxTokens:   maxTokens,
+	})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// memoryItem is one node in the LRU's backing list.
+type memoryItem struct {
+	key       string
+	entry     *Entry
+	expiresAt time.Time
+}
+
+// memoryStore is an in-memory, size-bounded LRU cache with per-entry TTL.
+type memoryStore struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+// NewMemoryStore builds an in-memory Store holding at most capacity
+// entries, evicting the least recently used once full.
+func NewMemoryStore(capacity int) Store {
+	if capacity <= 0 {
+		capacity = 1000
+	}
+	return &memoryStore{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (s *memoryStore) Get(ctx context.Context, key string) (*Entry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+	item := el.Value.(*memoryItem)
+	if time.Now().After(item.expiresAt) {
+		s.ll.Remove(el)
+		delete(s.items, key)
+		return nil, false, nil
+	}
+	s.ll.MoveToFront(el)
+	return item.entry, true, nil
+}
+
+func (s *memoryStore) Set(ctx context.Context, key string, entry *Entry, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.items[key]; ok {
+		s.ll.MoveToFront(el)
+		item := el.Value.(*memoryItem)
+		item.entry = entry
+		item.expiresAt = time.Now().Add(ttl)
+		return nil
+	}
+
+	el := s.ll.PushFront(&memoryItem{key: key, entry: entry, expiresAt: time.Now().Add(ttl)})
+	s.items[key] = el
+
+	if s.ll.Len() > s.capacity {
+		oldest := s.ll.Back()
+		if oldest != nil {
+			s.ll.Remove(oldest)
+			delete(s.items, oldest.Value.(*memoryItem).key)
+		}
+	}
+	return nil
+}
+
+// redisStore persists cache entries in Redis as JSON, relying on Redis's
+// own key expiry to enforce the TTL.
+type redisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisStore builds a Redis-backed Store. prefix namespaces cache keys
+// (defaulting to "gwcache:") so they don't collide with other Redis users.
+func NewRedisStore(client *redis.Client, prefix string) Store {
+	if prefix == "" {
+		prefix = "gwcache:"
+	}
+	return &redisStore{client: client, prefix: prefix}
+}
+
+func (s *redisStore) Get(ctx context.Context, key string) (*Entry, bool, error) {
+	data, err := s.client.Get(ctx, s.prefix+key).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, false, err
+	}
+	return &entry, true, nil
+}
+
+func (s *redisStore) Set(ctx context.Context, key string, entry *Entry, ttl time.Duration) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, s.prefix+key, data, ttl).Err()
+}
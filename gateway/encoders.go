@@ -0,0 +1,291 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ResponseEncoder formats a streamed or non-streamed chat completion into a
+// specific client API's wire format, so handleStreamingChat and
+// handleNonStreamingChat stay protocol-agnostic. A new encoder is
+// constructed per request and carries whatever framing state its protocol
+// needs (message ID, whether the opening event has been sent yet).
+type ResponseEncoder interface {
+	// ContentType is the value to set on the streaming response's
+	// Content-Type header.
+	ContentType() string
+	// WriteChunk frames a single delta and writes it to w, returning the
+	// number of bytes written (for the gateway_sse_bytes_total metric).
+	WriteChunk(w io.Writer, chunk *BackendChunkData) (int, error)
+	// WriteDone writes whatever terminal framing a normal stream end
+	// requires (e.g. OpenAI's "data: [DONE]").
+	WriteDone(w io.Writer) error
+	// WriteError writes a mid-stream error in the protocol's error framing.
+	WriteError(w io.Writer, message string) error
+	// WriteFull writes a complete non-streaming response.
+	WriteFull(w http.ResponseWriter, content, finishReason string) error
+}
+
+// openAIEncoder produces the OpenAI chat completions SSE/JSON shape; this
+// is the gateway's original wire format, now factored out of
+// handleStreamingChat/handleNonStreamingChat.
+type openAIEncoder struct {
+	chatID  string
+	model   string
+	created int64
+}
+
+func newOpenAIEncoder(model string) *openAIEncoder {
+	return &openAIEncoder{
+		chatID:  fmt.Sprintf("chatcmpl-%s", uuid.New().String()),
+		model:   model,
+		created: time.Now().Unix(),
+	}
+}
+
+func (e *openAIEncoder) ContentType() string { return "text/event-stream" }
+
+func (e *openAIEncoder) WriteChunk(w io.Writer, chunk *BackendChunkData) (int, error) {
+	delta := map[string]interface{}{}
+	if chunk.Delta != "" {
+		delta["content"] = chunk.Delta
+	}
+	if chunk.Delta == "" && chunk.Done {
+		delta["content"] = ""
+	}
+
+	sseData := OpenAIChatResponse{
+		ID:      e.chatID,
+		Object:  "chat.completion.chunk",
+		Created: e.created,
+		Model:   e.model,
+		Choices: []OpenAIChatChoice{
+			{Index: 0, Delta: delta, FinishReason: chunk.FinishReason},
+		},
+	}
+	data, _ := json.Marshal(sseData)
+	return fmt.Fprintf(w, "data: %s\n\n", string(data))
+}
+
+func (e *openAIEncoder) WriteDone(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "data: [DONE]\n\n")
+	return err
+}
+
+func (e *openAIEncoder) WriteError(w io.Writer, message string) error {
+	errData := map[string]interface{}{
+		"error": map[string]interface{}{
+			"message": message,
+			"type":    "server_error",
+		},
+	}
+	data, _ := json.Marshal(errData)
+	_, err := fmt.Fprintf(w, "data: %s\n\n", string(data))
+	return err
+}
+
+func (e *openAIEncoder) WriteFull(w http.ResponseWriter, content, finishReason string) error {
+	resp := OpenAIChatResponse{
+		ID:      e.chatID,
+		Object:  "chat.completion",
+		Created: e.created,
+		Model:   e.model,
+		Choices: []OpenAIChatChoice{
+			{
+				Index: 0,
+				Message: map[string]interface{}{
+					"role":    "assistant",
+					"content": content,
+				},
+				FinishReason: finishReason,
+			},
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(resp)
+}
+
+// ollamaEncoder produces Ollama's native NDJSON shape: one JSON object per
+// line, the last one carrying "done": true instead of a separate
+// terminator.
+type ollamaEncoder struct {
+	model string
+}
+
+func newOllamaEncoder(model string) *ollamaEncoder {
+	return &ollamaEncoder{model: model}
+}
+
+func (e *ollamaEncoder) ContentType() string { return "application/x-ndjson" }
+
+func (e *ollamaEncoder) WriteChunk(w io.Writer, chunk *BackendChunkData) (int, error) {
+	line := map[string]interface{}{
+		"model":      e.model,
+		"created_at": time.Now().UTC().Format(time.RFC3339),
+		"message": map[string]interface{}{
+			"role":    "assistant",
+			"content": chunk.Delta,
+		},
+		"done": chunk.Done,
+	}
+	if chunk.Done && chunk.FinishReason != "" {
+		line["done_reason"] = chunk.FinishReason
+	}
+	data, _ := json.Marshal(line)
+	return fmt.Fprintf(w, "%s\n", string(data))
+}
+
+// WriteDone is a no-op: the final WriteChunk call already carries "done":
+// true, which is Ollama's own stream terminator.
+func (e *ollamaEncoder) WriteDone(w io.Writer) error { return nil }
+
+func (e *ollamaEncoder) WriteError(w io.Writer, message string) error {
+	line := map[string]interface{}{"error": message}
+	data, _ := json.Marshal(line)
+	_, err := fmt.Fprintf(w, "%s\n", string(data))
+	return err
+}
+
+func (e *ollamaEncoder) WriteFull(w http.ResponseWriter, content, finishReason string) error {
+	resp := map[string]interface{}{
+		"model":      e.model,
+		"created_at": time.Now().UTC().Format(time.RFC3339),
+		"message": map[string]interface{}{
+			"role":    "assistant",
+			"content": content,
+		},
+		"done": true,
+	}
+	if finishReason != "" {
+		resp["done_reason"] = finishReason
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(resp)
+}
+
+// anthropicEncoder produces the Anthropic Messages API SSE event sequence:
+// message_start and content_block_start once, a content_block_delta per
+// text chunk, then content_block_stop/message_delta/message_stop to close
+// out the message.
+type anthropicEncoder struct {
+	msgID   string
+	model   string
+	started bool
+}
+
+func newAnthropicEncoder(model string) *anthropicEncoder {
+	return &anthropicEncoder{msgID: fmt.Sprintf("msg_%s", uuid.New().String()), model: model}
+}
+
+func (e *anthropicEncoder) ContentType() string { return "text/event-stream" }
+
+func writeAnthropicEvent(w io.Writer, event string, payload interface{}) (int, error) {
+	data, _ := json.Marshal(payload)
+	return fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, string(data))
+}
+
+func (e *anthropicEncoder) WriteChunk(w io.Writer, chunk *BackendChunkData) (int, error) {
+	total := 0
+	if !e.started {
+		e.started = true
+		n, err := writeAnthropicEvent(w, "message_start", map[string]interface{}{
+			"type": "message_start",
+			"message": map[string]interface{}{
+				"id":          e.msgID,
+				"type":        "message",
+				"role":        "assistant",
+				"model":       e.model,
+				"content":     []interface{}{},
+				"stop_reason": nil,
+				"usage":       map[string]interface{}{"input_tokens": 0, "output_tokens": 0},
+			},
+		})
+		total += n
+		if err != nil {
+			return total, err
+		}
+		n, err = writeAnthropicEvent(w, "content_block_start", map[string]interface{}{
+			"type":          "content_block_start",
+			"index":         0,
+			"content_block": map[string]interface{}{"type": "text", "text": ""},
+		})
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+
+	if chunk.Delta != "" {
+		n, err := writeAnthropicEvent(w, "content_block_delta", map[string]interface{}{
+			"type":  "content_block_delta",
+			"index": 0,
+			"delta": map[string]interface{}{"type": "text_delta", "text": chunk.Delta},
+		})
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+
+	if chunk.Done {
+		n, err := writeAnthropicEvent(w, "content_block_stop", map[string]interface{}{"type": "content_block_stop", "index": 0})
+		total += n
+		if err != nil {
+			return total, err
+		}
+		n, err = writeAnthropicEvent(w, "message_delta", map[string]interface{}{
+			"type":  "message_delta",
+			"delta": map[string]interface{}{"stop_reason": anthropicStopReason(chunk.FinishReason)},
+		})
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+func (e *anthropicEncoder) WriteDone(w io.Writer) error {
+	_, err := writeAnthropicEvent(w, "message_stop", map[string]interface{}{"type": "message_stop"})
+	return err
+}
+
+func (e *anthropicEncoder) WriteError(w io.Writer, message string) error {
+	_, err := writeAnthropicEvent(w, "error", map[string]interface{}{
+		"type":  "error",
+		"error": map[string]interface{}{"type": "api_error", "message": message},
+	})
+	return err
+}
+
+func (e *anthropicEncoder) WriteFull(w http.ResponseWriter, content, finishReason string) error {
+	resp := map[string]interface{}{
+		"id":    e.msgID,
+		"type":  "message",
+		"role":  "assistant",
+		"model": e.model,
+		"content": []map[string]interface{}{
+			{"type": "text", "text": content},
+		},
+		"stop_reason": anthropicStopReason(finishReason),
+		"usage":       map[string]interface{}{"input_tokens": 0, "output_tokens": 0},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(resp)
+}
+
+// anthropicStopReason maps the internal (OpenAI-shaped) finish reason onto
+// Anthropic's stop_reason vocabulary.
+func anthropicStopReason(finishReason string) string {
+	switch finishReason {
+	case "length":
+		return "max_tokens"
+	default:
+		return "end_turn"
+	}
+}
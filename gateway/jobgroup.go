@@ -0,0 +1,52 @@
+package main
+
+import "sync"
+
+// jobCall is the in-flight (or just-finished) result for one jobGroup key.
+type jobCall struct {
+	wg    sync.WaitGroup
+	jobID string
+	err   error
+}
+
+// jobGroup coalesces concurrent createBackendJob calls that share the same
+// cache key, so duplicate in-flight requests (e.g. a client retry racing
+// the original, or two users asking the same question at once) attach to
+// the backend job the first caller created instead of creating their own.
+type jobGroup struct {
+	mu    sync.Mutex
+	calls map[string]*jobCall
+}
+
+func newJobGroup() *jobGroup {
+	return &jobGroup{calls: make(map[string]*jobCall)}
+}
+
+// Do runs fn for key if no call for that key is already in flight;
+// otherwise it blocks until the in-flight call finishes and returns its
+// result. An empty key always runs fn uncoalesced.
+func (g *jobGroup) Do(key string, fn func() (string, error)) (string, error) {
+	if key == "" {
+		return fn()
+	}
+
+	g.mu.Lock()
+	if call, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		call.wg.Wait()
+		return call.jobID, call.err
+	}
+	call := &jobCall{}
+	call.wg.Add(1)
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	call.jobID, call.err = fn()
+	call.wg.Done()
+
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+
+	return call.jobID, call.err
+}
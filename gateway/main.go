@@ -7,14 +7,25 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
+	"github.com/go-redis/redis/v8"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+
+	"github.com/CyberGarden2025/ollama-ha-proxy/gateway/backendpool"
+	"github.com/CyberGarden2025/ollama-ha-proxy/gateway/cache"
+	"github.com/CyberGarden2025/ollama-ha-proxy/gateway/metrics"
+	"github.com/CyberGarden2025/ollama-ha-proxy/gateway/modelregistry"
+	"github.com/CyberGarden2025/ollama-ha-proxy/gateway/ratelimit"
 )
 
 type OpenAIChatRequest struct {
@@ -54,8 +65,8 @@ type BackendJobResponse struct {
 }
 
 type BackendEventsResponse struct {
-	Status string              `json:"status"`
-	Chunks []BackendChunkData  `json:"chunks"`
+	Status string             `json:"status"`
+	Chunks []BackendChunkData `json:"chunks"`
 }
 
 type BackendChunkData struct {
@@ -67,24 +78,88 @@ type BackendChunkData struct {
 }
 
 type Config struct {
-	BackendURL         string
+	BackendURLs        []string
 	PollIntervalMS     int
 	RetryBackoffInitMS int
 	RetryBackoffMaxMS  int
 	JobTimeoutMS       int
 	APIKeyRequired     bool
 	APIKey             string
+
+	KeysConfigPath          string
+	DefaultRPM              int
+	DefaultTPM              int
+	DefaultConcurrency      int
+	GlobalConcurrency       int
+	AdmissionQueueTimeoutMS int
+	RateLimitIdleTTLMS      int
+	RateLimitSweepMS        int
+
+	BackendTransportMode string
+
+	LBStrategy              string
+	HealthCheckPath         string
+	HealthCheckIntervalMS   int
+	CircuitFailureThreshold int
+	CircuitCooldownMS       int
+
+	ModelsConfigPath       string
+	ModelsTagsPath         string
+	ModelRefreshIntervalMS int
+
+	CacheEnabled        bool
+	CacheStore          string
+	CacheCapacity       int
+	CacheTTLSeconds     int
+	CacheReplayPacingMS int
+	CacheRedisAddr      string
+
+	// TrustedProxyCount is how many reverse-proxy hops sit in front of the
+	// gateway. 0 (the default) means X-Forwarded-For is never trusted,
+	// since any anonymous client could set it to whatever IP it wants;
+	// see clientIP.
+	TrustedProxyCount int
 }
 
 func LoadConfig() *Config {
 	cfg := &Config{
-		BackendURL:         getEnv("BACKEND_PROXY_URL", "http://localhost:5345"),
+		BackendURLs:        splitAndTrim(getEnv("BACKEND_PROXY_URLS", getEnv("BACKEND_PROXY_URL", "http://localhost:5345"))),
 		PollIntervalMS:     getEnvInt("POLL_INTERVAL_MS", 500),
 		RetryBackoffInitMS: getEnvInt("RETRY_BACKOFF_INIT_MS", 1000),
 		RetryBackoffMaxMS:  getEnvInt("RETRY_BACKOFF_MAX_MS", 30000),
 		JobTimeoutMS:       getEnvInt("JOB_TIMEOUT_MS", 1800000),
 		APIKeyRequired:     getEnv("OPENAI_API_KEY_REQUIRED", "false") == "true",
 		APIKey:             getEnv("OPENAI_API_KEY", ""),
+
+		KeysConfigPath:          getEnv("KEYS_CONFIG_PATH", "keys.yaml"),
+		DefaultRPM:              getEnvInt("RATE_LIMIT_DEFAULT_RPM", 60),
+		DefaultTPM:              getEnvInt("RATE_LIMIT_DEFAULT_TPM", 100000),
+		DefaultConcurrency:      getEnvInt("RATE_LIMIT_DEFAULT_CONCURRENCY", 4),
+		GlobalConcurrency:       getEnvInt("GLOBAL_CONCURRENCY", 64),
+		AdmissionQueueTimeoutMS: getEnvInt("ADMISSION_QUEUE_TIMEOUT_MS", 5000),
+		RateLimitIdleTTLMS:      getEnvInt("RATE_LIMIT_IDLE_TTL_MS", 10*60*1000),
+		RateLimitSweepMS:        getEnvInt("RATE_LIMIT_SWEEP_MS", 60*1000),
+
+		LBStrategy:              getEnv("LB_STRATEGY", "round_robin"),
+		HealthCheckPath:         getEnv("HEALTH_CHECK_PATH", "/stats"),
+		HealthCheckIntervalMS:   getEnvInt("HEALTH_CHECK_INTERVAL_MS", 10000),
+		CircuitFailureThreshold: getEnvInt("CIRCUIT_FAILURE_THRESHOLD", 3),
+		CircuitCooldownMS:       getEnvInt("CIRCUIT_COOLDOWN_MS", 30000),
+
+		BackendTransportMode: getEnv("BACKEND_TRANSPORT", "auto"),
+
+		ModelsConfigPath:       getEnv("MODELS_CONFIG_PATH", "models.yaml"),
+		ModelsTagsPath:         getEnv("MODELS_TAGS_PATH", "/api/tags"),
+		ModelRefreshIntervalMS: getEnvInt("MODEL_REFRESH_INTERVAL_MS", 30000),
+
+		CacheEnabled:        getEnv("CACHE_ENABLED", "false") == "true",
+		CacheStore:          getEnv("CACHE_STORE", "memory"),
+		CacheCapacity:       getEnvInt("CACHE_CAPACITY", 1000),
+		CacheTTLSeconds:     getEnvInt("CACHE_TTL_SECONDS", 300),
+		CacheReplayPacingMS: getEnvInt("CACHE_REPLAY_PACING_MS", 20),
+		CacheRedisAddr:      getEnv("CACHE_REDIS_ADDR", "localhost:6379"),
+
+		TrustedProxyCount: getEnvInt("TRUSTED_PROXY_COUNT", 0),
 	}
 	return cfg
 }
@@ -105,18 +180,122 @@ func getEnvInt(key string, defaultVal int) int {
 	return defaultVal
 }
 
+// splitAndTrim splits a comma-separated env var into trimmed, non-empty
+// values, e.g. "http://a:5345, http://b:5345" -> ["http://a:5345",
+// "http://b:5345"].
+func splitAndTrim(val string) []string {
+	var out []string
+	for _, part := range strings.Split(val, ",") {
+		part = strings.TrimSpace(part)
+		if part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// gatewayMetrics bundles the Prometheus collectors used across
+// Proxy1Server's handlers, keyed by model where useful. Built once and
+// shared so label series accumulate instead of being recreated per request.
+type gatewayMetrics struct {
+	registry *metrics.Registry
+
+	requestsInFlight *metrics.GaugeVec
+	requestDuration  *metrics.HistogramVec
+	ttft             *metrics.HistogramVec
+	pollLatency      *metrics.HistogramVec
+	backendErrors    *metrics.CounterVec
+	sseBytes         *metrics.CounterVec
+	sseTokens        *metrics.CounterVec
+	cacheHits        *metrics.CounterVec
+	cacheMisses      *metrics.CounterVec
+}
+
+func newGatewayMetrics() *gatewayMetrics {
+	reg := metrics.NewRegistry()
+	return &gatewayMetrics{
+		registry:         reg,
+		requestsInFlight: reg.Gauge("gateway_requests_in_flight", "Number of chat completion requests currently being served, by model.", "model"),
+		requestDuration:  reg.Histogram("gateway_request_duration_seconds", "End-to-end chat completion request duration.", "model", "streaming"),
+		ttft:             reg.Histogram("gateway_time_to_first_token_seconds", "Time from job creation to the first streamed delta.", "model"),
+		pollLatency:      reg.Histogram("gateway_backend_poll_duration_seconds", "Latency of a single poll against the backend events endpoint.", "model"),
+		backendErrors:    reg.Counter("gateway_backend_errors_total", "Backend errors encountered while polling or creating jobs, by kind.", "model", "kind"),
+		sseBytes:         reg.Counter("gateway_sse_bytes_total", "Bytes of SSE payload emitted to clients.", "model"),
+		sseTokens:        reg.Counter("gateway_sse_tokens_total", "Approximate tokens (delta chunks) emitted to clients over SSE.", "model"),
+		cacheHits:        reg.Counter("gateway_cache_hits_total", "Response cache hits, by model.", "model"),
+		cacheMisses:      reg.Counter("gateway_cache_misses_total", "Response cache misses, by model.", "model"),
+	}
+}
+
 type Proxy1Server struct {
-	config     *Config
-	httpClient *http.Client
+	config      *Config
+	httpClient  *http.Client
+	metrics     *gatewayMetrics
+	limiter     *ratelimit.Manager
+	pool        *backendpool.Pool
+	modelRouter *ModelRouter
+	transport   BackendTransport
+	cache       cache.Store
+	jobs        *jobGroup
 }
 
-func NewProxy1Server(cfg *Config) *Proxy1Server {
-	return &Proxy1Server{
+func NewProxy1Server(cfg *Config) (*Proxy1Server, error) {
+	defaultTier := ratelimit.Tier{RPM: cfg.DefaultRPM, TPM: cfg.DefaultTPM, Concurrency: cfg.DefaultConcurrency}
+	limiter, err := ratelimit.NewManager(defaultTier, cfg.GlobalConcurrency, time.Duration(cfg.AdmissionQueueTimeoutMS)*time.Millisecond, cfg.KeysConfigPath)
+	if err != nil {
+		return nil, fmt.Errorf("init rate limiter: %w", err)
+	}
+	limiter.Start(time.Duration(cfg.RateLimitIdleTTLMS)*time.Millisecond, time.Duration(cfg.RateLimitSweepMS)*time.Millisecond)
+
+	backends := make([]backendpool.Backend, len(cfg.BackendURLs))
+	for i, url := range cfg.BackendURLs {
+		backends[i] = backendpool.Backend{URL: url, Weight: 1}
+	}
+	pool, err := backendpool.NewPool(backends, backendpool.Strategy(cfg.LBStrategy), cfg.HealthCheckPath, cfg.CircuitFailureThreshold, time.Duration(cfg.CircuitCooldownMS)*time.Millisecond)
+	if err != nil {
+		return nil, fmt.Errorf("init backend pool: %w", err)
+	}
+	pool.Start(time.Duration(cfg.HealthCheckIntervalMS) * time.Millisecond)
+
+	registry, err := modelregistry.NewRegistry(cfg.ModelsTagsPath, cfg.ModelsConfigPath)
+	if err != nil {
+		return nil, fmt.Errorf("init model registry: %w", err)
+	}
+	registry.Start(pool.BackendURLs, time.Duration(cfg.ModelRefreshIntervalMS)*time.Millisecond)
+
+	var cacheStore cache.Store
+	if cfg.CacheEnabled {
+		switch cfg.CacheStore {
+		case "redis":
+			cacheStore = cache.NewRedisStore(redis.NewClient(&redis.Options{Addr: cfg.CacheRedisAddr}), "")
+		default:
+			cacheStore = cache.NewMemoryStore(cfg.CacheCapacity)
+		}
+	}
+
+	s := &Proxy1Server{
 		config: cfg,
 		httpClient: &http.Client{
 			Timeout: time.Duration(cfg.JobTimeoutMS) * time.Millisecond,
 		},
+		metrics:     newGatewayMetrics(),
+		limiter:     limiter,
+		pool:        pool,
+		modelRouter: newModelRouter(registry),
+		cache:       cacheStore,
+		jobs:        newJobGroup(),
+	}
+
+	switch cfg.BackendTransportMode {
+	case "poll":
+		s.transport = newPollTransport(s)
+	case "stream":
+		s.transport = newSSETransport(s)
+	default:
+		s.transport = newFallbackTransport(s)
 	}
+
+	return s, nil
 }
 
 func (s *Proxy1Server) validateAuth(r *http.Request) error {
@@ -137,7 +316,79 @@ func (s *Proxy1Server) validateAuth(r *http.Request) error {
 	return nil
 }
 
+// apiKeyFromRequest extracts the bearer token (if any) to use as the
+// rate-limit identity. Unlike validateAuth, this runs regardless of
+// APIKeyRequired so per-key quotas apply even when auth itself is
+// disabled, falling back to the caller's IP.
+func (s *Proxy1Server) apiKeyFromRequest(r *http.Request) string {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		return ""
+	}
+	return strings.TrimPrefix(authHeader, "Bearer ")
+}
+
+// clientIP returns the request's remote address with any port stripped. It
+// only honors X-Forwarded-For when s.config.TrustedProxyCount is positive,
+// taking the entry that many hops from the right (the IP the nearest
+// trusted proxy appended); an anonymous caller with no trusted proxy in
+// front of it could otherwise set the header to a fresh value on every
+// request and get a fresh rate-limit identity each time.
+func (s *Proxy1Server) clientIP(r *http.Request) string {
+	host := r.RemoteAddr
+	if idx := strings.LastIndexByte(host, ':'); idx != -1 {
+		host = host[:idx]
+	}
+
+	if s.config.TrustedProxyCount <= 0 {
+		return host
+	}
+	fwd := r.Header.Get("X-Forwarded-For")
+	if fwd == "" {
+		return host
+	}
+	parts := strings.Split(fwd, ",")
+	idx := len(parts) - s.config.TrustedProxyCount
+	if idx < 0 {
+		idx = 0
+	}
+	return strings.TrimSpace(parts[idx])
+}
+
+// errKind classifies a backend error for the gateway_backend_errors_total
+// counter: "rate-limit", "timeout", or "transport" (anything else, e.g. a
+// non-200 status or a malformed body).
+func errKind(err error) string {
+	if _, ok := err.(*RateLimitError); ok {
+		return "rate-limit"
+	}
+	if te, ok := err.(interface{ Timeout() bool }); ok && te.Timeout() {
+		return "timeout"
+	}
+	return "transport"
+}
+
+// createBackendJob dispatches req to a backend, coalescing concurrent
+// identical requests (same cache key) onto a single backend job via
+// s.jobs so duplicate callers share one result instead of each starting
+// their own. Every caller that gets a jobID back, whether it created the
+// job or was coalesced onto one already in flight, is attached to the
+// pool's sticky binding for that job; each caller owns exactly one
+// matching deferred UnbindJob, so the binding isn't dropped until the
+// last attached caller is done with it.
 func (s *Proxy1Server) createBackendJob(req OpenAIChatRequest) (string, error) {
+	key := cache.Key(req.Model, req.Messages, req.Temperature, req.TopP, req.MaxTokens)
+	jobID, err := s.jobs.Do(key, func() (string, error) {
+		return s.createBackendJobUncoalesced(req)
+	})
+	if err != nil {
+		return "", err
+	}
+	s.pool.AttachJob(jobID)
+	return jobID, nil
+}
+
+func (s *Proxy1Server) createBackendJobUncoalesced(req OpenAIChatRequest) (string, error) {
 	options := make(map[string]interface{})
 	if req.Temperature != 0 {
 		options["temperature"] = req.Temperature
@@ -149,8 +400,13 @@ func (s *Proxy1Server) createBackendJob(req OpenAIChatRequest) (string, error) {
 		options["num_predict"] = req.MaxTokens
 	}
 
+	canonicalModel, backendURLs, err := s.modelRouter.Resolve(req.Model)
+	if err != nil {
+		return "", err
+	}
+
 	backendReq := BackendJobRequest{
-		Model:    req.Model,
+		Model:    canonicalModel,
 		Messages: req.Messages,
 		Options:  options,
 	}
@@ -160,27 +416,44 @@ func (s *Proxy1Server) createBackendJob(req OpenAIChatRequest) (string, error) {
 		return "", err
 	}
 
-	resp, err := http.Post(s.config.BackendURL+"/jobs", "application/json", bytes.NewReader(payload))
+	backend, err := s.pool.PickFrom(backendURLs)
 	if err != nil {
+		s.metrics.backendErrors.WithLabelValues(canonicalModel, "transport").Inc()
+		return "", err
+	}
+	release := s.pool.Acquire(backend.URL)
+	defer release()
+
+	resp, err := http.Post(backend.URL+"/jobs", "application/json", bytes.NewReader(payload))
+	if err != nil {
+		s.pool.ReportResult(backend.URL, false)
+		s.metrics.backendErrors.WithLabelValues(canonicalModel, errKind(err)).Inc()
 		return "", err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode == http.StatusTooManyRequests {
 		body, _ := io.ReadAll(resp.Body)
+		s.metrics.backendErrors.WithLabelValues(canonicalModel, "rate-limit").Inc()
 		return "", &RateLimitError{Message: string(body)}
 	}
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
+		s.pool.ReportResult(backend.URL, false)
+		s.metrics.backendErrors.WithLabelValues(canonicalModel, "transport").Inc()
 		return "", fmt.Errorf("backend error: %s", string(body))
 	}
 
 	var jobResp BackendJobResponse
 	if err := json.NewDecoder(resp.Body).Decode(&jobResp); err != nil {
+		s.pool.ReportResult(backend.URL, false)
+		s.metrics.backendErrors.WithLabelValues(canonicalModel, "transport").Inc()
 		return "", err
 	}
 
+	s.pool.ReportResult(backend.URL, true)
+	s.pool.BindJob(jobResp.JobID, backend.URL)
 	return jobResp.JobID, nil
 }
 
@@ -192,27 +465,39 @@ func (e *RateLimitError) Error() string {
 	return e.Message
 }
 
-func (s *Proxy1Server) pollBackendEvents(ctx context.Context, jobID string, lastSeq int) (*BackendEventsResponse, error) {
-	url := fmt.Sprintf("%s/jobs/%s/events?from_seq=%d", s.config.BackendURL, jobID, lastSeq)
-	
+func (s *Proxy1Server) pollBackendEvents(ctx context.Context, model, jobID string, lastSeq int) (*BackendEventsResponse, error) {
+	backend, err := s.pool.BackendForJob(jobID)
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("%s/jobs/%s/events?from_seq=%d", backend.URL, jobID, lastSeq)
+
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
 
 	client := &http.Client{Timeout: 10 * time.Second}
+	pollStart := time.Now()
 	resp, err := client.Do(req)
+	s.metrics.pollLatency.Observe(time.Since(pollStart).Seconds(), model)
 	if err != nil {
+		s.pool.ReportResult(backend.URL, false)
+		s.metrics.backendErrors.WithLabelValues(model, errKind(err)).Inc()
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
+		s.pool.ReportResult(backend.URL, false)
+		s.metrics.backendErrors.WithLabelValues(model, "transport").Inc()
 		return nil, fmt.Errorf("backend status: %d", resp.StatusCode)
 	}
+	s.pool.ReportResult(backend.URL, true)
 
 	var eventsResp BackendEventsResponse
 	if err := json.NewDecoder(resp.Body).Decode(&eventsResp); err != nil {
+		s.metrics.backendErrors.WithLabelValues(model, "transport").Inc()
 		return nil, err
 	}
 
@@ -231,25 +516,130 @@ func (s *Proxy1Server) ChatCompletions(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	s.dispatchChat(w, r, req, newOpenAIEncoder(req.Model))
+}
+
+// dispatchChat runs the admission check and chunk-format-agnostic streaming
+// or non-streaming flow shared by every protocol endpoint (OpenAI, Ollama,
+// Anthropic); each endpoint only differs in how it parses the request body
+// and which ResponseEncoder it hands in.
+func (s *Proxy1Server) dispatchChat(w http.ResponseWriter, r *http.Request, req OpenAIChatRequest, enc ResponseEncoder) {
+	logFieldsFromContext(r.Context()).setModel(req.Model)
+
+	estimatedTokens := req.MaxTokens
+	if estimatedTokens == 0 {
+		estimatedTokens = 1
+	}
+	release, err := s.limiter.Admit(r.Context(), s.apiKeyFromRequest(r), s.clientIP(r), estimatedTokens)
+	if err != nil {
+		switch e := err.(type) {
+		case *ratelimit.QuotaExceededError:
+			w.Header().Set("Retry-After", strconv.Itoa(int(e.RetryAfter.Seconds())))
+			s.writeError(w, "rate limit exceeded", http.StatusTooManyRequests)
+		case *ratelimit.OverloadedError:
+			s.writeError(w, "service overloaded", http.StatusServiceUnavailable)
+		default:
+			s.writeError(w, "request aborted", http.StatusServiceUnavailable)
+		}
+		return
+	}
+	defer release()
+
+	// metricModel is req.Model resolved against the live model registry,
+	// falling back to "unknown" for anything it doesn't recognize, so a
+	// client can't grow the metrics registry's label maps (which never
+	// evict) without bound just by sending a new bogus model name per
+	// request.
+	metricModel := s.modelRouter.CanonicalLabel(req.Model)
+
+	if s.cacheEligible(r) {
+		key := cache.Key(req.Model, req.Messages, req.Temperature, req.TopP, req.MaxTokens)
+		if entry, ok, err := s.cache.Get(r.Context(), key); err == nil && ok {
+			s.metrics.cacheHits.WithLabelValues(metricModel).Inc()
+			s.serveCached(w, req, enc, entry)
+			return
+		}
+		s.metrics.cacheMisses.WithLabelValues(metricModel).Inc()
+	}
+
 	if req.Stream {
-		s.handleStreamingChat(w, r, req)
+		s.handleStreamingChat(w, r, req, enc, metricModel)
 	} else {
-		s.handleNonStreamingChat(w, r, req)
+		s.handleNonStreamingChat(w, r, req, enc, metricModel)
 	}
 }
 
-func (s *Proxy1Server) handleStreamingChat(w http.ResponseWriter, r *http.Request, req OpenAIChatRequest) {
+// cacheEligible reports whether the response cache is enabled and the
+// client hasn't opted out via a standard Cache-Control: no-store header.
+func (s *Proxy1Server) cacheEligible(r *http.Request) bool {
+	if !s.config.CacheEnabled || s.cache == nil {
+		return false
+	}
+	return !strings.Contains(r.Header.Get("Cache-Control"), "no-store")
+}
+
+// serveCached replays a cached Entry through enc, either as a single
+// full response or, for streaming requests, as a paced sequence of
+// chunks so clients see the same shape of response as a live one.
+func (s *Proxy1Server) serveCached(w http.ResponseWriter, req OpenAIChatRequest, enc ResponseEncoder, entry *cache.Entry) {
+	if !req.Stream {
+		if err := enc.WriteFull(w, entry.Content, entry.FinishReason); err != nil {
+			log.Printf("failed to write cached response: %v", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", enc.ContentType())
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.writeError(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	pacing := time.Duration(s.config.CacheReplayPacingMS) * time.Millisecond
+	for i, chunk := range entry.Chunks {
+		c := chunk
+		enc.WriteChunk(w, &BackendChunkData{Delta: c.Delta, Done: c.Done, FinishReason: c.FinishReason})
+		flusher.Flush()
+		if i < len(entry.Chunks)-1 && pacing > 0 {
+			time.Sleep(pacing)
+		}
+	}
+	enc.WriteDone(w)
+	flusher.Flush()
+}
+
+func (s *Proxy1Server) handleStreamingChat(w http.ResponseWriter, r *http.Request, req OpenAIChatRequest, enc ResponseEncoder, metricModel string) {
+	inFlight := s.metrics.requestsInFlight.WithLabelValues(metricModel)
+	inFlight.Inc()
+	defer inFlight.Dec()
+
+	start := time.Now()
+	defer func() {
+		s.metrics.requestDuration.Observe(time.Since(start).Seconds(), metricModel, "true")
+	}()
+
 	jobID, err := s.createBackendJob(req)
 	if err != nil {
 		if _, ok := err.(*RateLimitError); ok {
 			s.writeError(w, "Service overloaded. Please try again later.", http.StatusTooManyRequests)
 			return
 		}
+		if nf, ok := err.(*ModelNotFoundError); ok {
+			s.writeError(w, nf.Error(), http.StatusNotFound)
+			return
+		}
 		s.writeError(w, fmt.Sprintf("failed to create job: %v", err), http.StatusInternalServerError)
 		return
 	}
+	logFieldsFromContext(r.Context()).setJobID(jobID)
+	defer s.pool.UnbindJob(jobID)
 
-	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Content-Type", enc.ContentType())
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
 	w.Header().Set("X-Accel-Buffering", "no")
@@ -263,132 +653,116 @@ func (s *Proxy1Server) handleStreamingChat(w http.ResponseWriter, r *http.Reques
 	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(s.config.JobTimeoutMS)*time.Millisecond)
 	defer cancel()
 
-	chatID := fmt.Sprintf("chatcmpl-%s", uuid.New().String())
-	lastSeq := -1
-	retryDelay := s.config.RetryBackoffInitMS
-	created := time.Now().Unix()
+	firstTokenSeen := false
+	cacheable := s.cacheEligible(r)
+	var recorded cache.Entry
 
-	for {
-		select {
-		case <-ctx.Done():
-			s.writeSSEError(w, "timeout exceeded")
+	for ev := range s.transport.Stream(ctx, metricModel, jobID, -1) {
+		if ev.Err != nil {
+			enc.WriteError(w, "timeout exceeded")
 			flusher.Flush()
 			return
-		default:
-		}
-
-		events, err := s.pollBackendEvents(ctx, jobID, lastSeq)
-		if err != nil {
-			log.Printf("Poll error for job %s: %v", jobID, err)
-			time.Sleep(time.Duration(retryDelay) * time.Millisecond)
-			retryDelay = min(retryDelay*2, s.config.RetryBackoffMaxMS)
-			continue
 		}
 
-		retryDelay = s.config.RetryBackoffInitMS
-
-		for _, chunk := range events.Chunks {
-			if chunk.Seq <= lastSeq {
-				continue
-			}
-			lastSeq = chunk.Seq
-
+		if ev.Chunk != nil {
+			chunk := ev.Chunk
 			if chunk.Error != "" {
-				s.writeSSEError(w, chunk.Error)
+				enc.WriteError(w, chunk.Error)
 				flusher.Flush()
 				return
 			}
 
-			delta := map[string]interface{}{}
-			if chunk.Delta != "" {
-				delta["content"] = chunk.Delta
-			}
-			if chunk.Delta == "" && chunk.Done {
-				delta["content"] = ""
+			if !firstTokenSeen {
+				firstTokenSeen = true
+				s.metrics.ttft.Observe(time.Since(start).Seconds(), metricModel)
 			}
 
-			sseData := OpenAIChatResponse{
-				ID:      chatID,
-				Object:  "chat.completion.chunk",
-				Created: created,
-				Model:   req.Model,
-				Choices: []OpenAIChatChoice{
-					{
-						Index:        0,
-						Delta:        delta,
-						FinishReason: chunk.FinishReason,
-					},
-				},
+			n, _ := enc.WriteChunk(w, chunk)
+			flusher.Flush()
+			s.metrics.sseBytes.WithLabelValues(metricModel).Add(float64(n))
+			if chunk.Delta != "" {
+				s.metrics.sseTokens.WithLabelValues(metricModel).Inc()
 			}
 
-			data, _ := json.Marshal(sseData)
-			fmt.Fprintf(w, "data: %s\n\n", string(data))
-			flusher.Flush()
+			if cacheable {
+				recorded.Chunks = append(recorded.Chunks, cache.Chunk{Delta: chunk.Delta, Done: chunk.Done, FinishReason: chunk.FinishReason})
+			}
 
 			if chunk.Done {
-				fmt.Fprintf(w, "data: [DONE]\n\n")
+				enc.WriteDone(w)
 				flusher.Flush()
+				if cacheable {
+					s.storeCacheEntry(r, req, &recorded)
+				}
 				return
 			}
 		}
 
-		if events.Status == "completed" || events.Status == "failed" || events.Status == "cancelled" {
-			if events.Status != "completed" {
-				s.writeSSEError(w, fmt.Sprintf("job %s", events.Status))
+		if ev.Status == "completed" || ev.Status == "failed" || ev.Status == "cancelled" {
+			if ev.Status != "completed" {
+				enc.WriteError(w, fmt.Sprintf("job %s", ev.Status))
 			} else {
-				fmt.Fprintf(w, "data: [DONE]\n\n")
+				enc.WriteDone(w)
+				if cacheable {
+					s.storeCacheEntry(r, req, &recorded)
+				}
 			}
 			flusher.Flush()
 			return
 		}
+	}
+}
 
-		time.Sleep(time.Duration(s.config.PollIntervalMS) * time.Millisecond)
+// storeCacheEntry saves entry under req's cache key, when caching is
+// enabled for this request.
+func (s *Proxy1Server) storeCacheEntry(r *http.Request, req OpenAIChatRequest, entry *cache.Entry) {
+	key := cache.Key(req.Model, req.Messages, req.Temperature, req.TopP, req.MaxTokens)
+	ttl := time.Duration(s.config.CacheTTLSeconds) * time.Second
+	if err := s.cache.Set(r.Context(), key, entry, ttl); err != nil {
+		log.Printf("failed to store cache entry: %v", err)
 	}
 }
 
-func (s *Proxy1Server) handleNonStreamingChat(w http.ResponseWriter, r *http.Request, req OpenAIChatRequest) {
+func (s *Proxy1Server) handleNonStreamingChat(w http.ResponseWriter, r *http.Request, req OpenAIChatRequest, enc ResponseEncoder, metricModel string) {
+	inFlight := s.metrics.requestsInFlight.WithLabelValues(metricModel)
+	inFlight.Inc()
+	defer inFlight.Dec()
+
+	start := time.Now()
+	defer func() {
+		s.metrics.requestDuration.Observe(time.Since(start).Seconds(), metricModel, "false")
+	}()
+
 	jobID, err := s.createBackendJob(req)
 	if err != nil {
 		if _, ok := err.(*RateLimitError); ok {
 			s.writeError(w, "Service overloaded. Please try again later.", http.StatusTooManyRequests)
 			return
 		}
+		if nf, ok := err.(*ModelNotFoundError); ok {
+			s.writeError(w, nf.Error(), http.StatusNotFound)
+			return
+		}
 		s.writeError(w, fmt.Sprintf("failed to create job: %v", err), http.StatusInternalServerError)
 		return
 	}
+	logFieldsFromContext(r.Context()).setJobID(jobID)
+	defer s.pool.UnbindJob(jobID)
 
 	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(s.config.JobTimeoutMS)*time.Millisecond)
 	defer cancel()
 
-	lastSeq := -1
-	retryDelay := s.config.RetryBackoffInitMS
 	var fullContent strings.Builder
 	var finishReason string
 
-	for {
-		select {
-		case <-ctx.Done():
+	for ev := range s.transport.Stream(ctx, metricModel, jobID, -1) {
+		if ev.Err != nil {
 			s.writeError(w, "timeout exceeded", http.StatusGatewayTimeout)
 			return
-		default:
 		}
 
-		events, err := s.pollBackendEvents(ctx, jobID, lastSeq)
-		if err != nil {
-			log.Printf("Poll error for job %s: %v", jobID, err)
-			time.Sleep(time.Duration(retryDelay) * time.Millisecond)
-			retryDelay = min(retryDelay*2, s.config.RetryBackoffMaxMS)
-			continue
-		}
-
-		retryDelay = s.config.RetryBackoffInitMS
-
-		for _, chunk := range events.Chunks {
-			if chunk.Seq <= lastSeq {
-				continue
-			}
-			lastSeq = chunk.Seq
-
+		if ev.Chunk != nil {
+			chunk := ev.Chunk
 			if chunk.Error != "" {
 				s.writeError(w, chunk.Error, http.StatusInternalServerError)
 				return
@@ -398,67 +772,24 @@ func (s *Proxy1Server) handleNonStreamingChat(w http.ResponseWriter, r *http.Req
 
 			if chunk.Done {
 				finishReason = chunk.FinishReason
-				break
 			}
 		}
 
-		if events.Status == "completed" {
-			resp := OpenAIChatResponse{
-				ID:      fmt.Sprintf("chatcmpl-%s", uuid.New().String()),
-				Object:  "chat.completion",
-				Created: time.Now().Unix(),
-				Model:   req.Model,
-				Choices: []OpenAIChatChoice{
-					{
-						Index: 0,
-						Message: map[string]interface{}{
-							"role":    "assistant",
-							"content": fullContent.String(),
-						},
-						FinishReason: finishReason,
-					},
-				},
+		if ev.Status == "completed" {
+			if err := enc.WriteFull(w, fullContent.String(), finishReason); err != nil {
+				log.Printf("failed to write response for job %s: %v", jobID, err)
+			}
+			if s.cacheEligible(r) {
+				s.storeCacheEntry(r, req, &cache.Entry{Content: fullContent.String(), FinishReason: finishReason})
 			}
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(resp)
 			return
 		}
 
-		if events.Status == "failed" || events.Status == "cancelled" {
-			s.writeError(w, fmt.Sprintf("job %s", events.Status), http.StatusInternalServerError)
+		if ev.Status == "failed" || ev.Status == "cancelled" {
+			s.writeError(w, fmt.Sprintf("job %s", ev.Status), http.StatusInternalServerError)
 			return
 		}
-
-		time.Sleep(time.Duration(s.config.PollIntervalMS) * time.Millisecond)
-	}
-}
-
-func (s *Proxy1Server) Models(w http.ResponseWriter, r *http.Request) {
-	if err := s.validateAuth(r); err != nil {
-		s.writeError(w, err.Error(), http.StatusUnauthorized)
-		return
 	}
-
-	models := map[string]interface{}{
-		"object": "list",
-		"data": []map[string]interface{}{
-			{
-				"id":       "gpt-oss:20b",
-				"object":   "model",
-				"created":  time.Now().Unix(),
-				"owned_by": "ollama",
-			},
-			{
-				"id":       "gpt-oss:120b",
-				"object":   "model",
-				"created":  time.Now().Unix(),
-				"owned_by": "ollama",
-			},
-		},
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(models)
 }
 
 func (s *Proxy1Server) BackendStats(w http.ResponseWriter, r *http.Request) {
@@ -467,20 +798,22 @@ func (s *Proxy1Server) BackendStats(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resp, err := http.Get(s.config.BackendURL + "/stats")
+	stats, err := s.pool.AggregateStats(r.Context())
 	if err != nil {
-		s.writeError(w, fmt.Sprintf("failed to get backend stats: %v", err), http.StatusInternalServerError)
-		return
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
 		s.writeError(w, "backend stats unavailable", http.StatusServiceUnavailable)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	io.Copy(w, resp.Body)
+	json.NewEncoder(w).Encode(stats)
+}
+
+// Metrics renders the registry in Prometheus text exposition format.
+func (s *Proxy1Server) Metrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if err := s.metrics.registry.Render(w); err != nil {
+		log.Printf("failed to write metrics: %v", err)
+	}
 }
 
 func (s *Proxy1Server) writeError(w http.ResponseWriter, message string, statusCode int) {
@@ -495,17 +828,6 @@ func (s *Proxy1Server) writeError(w http.ResponseWriter, message string, statusC
 	})
 }
 
-func (s *Proxy1Server) writeSSEError(w http.ResponseWriter, message string) {
-	errData := map[string]interface{}{
-		"error": map[string]interface{}{
-			"message": message,
-			"type":    "server_error",
-		},
-	}
-	data, _ := json.Marshal(errData)
-	fmt.Fprintf(w, "data: %s\n\n", string(data))
-}
-
 func min(a, b int) int {
 	if a < b {
 		return a
@@ -513,21 +835,133 @@ func min(a, b int) int {
 	return b
 }
 
+type requestIDKey struct{}
+type logFieldsKey struct{}
+
+// requestIDFromContext returns the request ID stashed by requestIDMiddleware,
+// or "" if none is present (e.g. in tests that call handlers directly).
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// requestLogFields carries model/job_id from deep inside a handler back up
+// to requestIDMiddleware's access log line, so the two don't need to thread
+// extra return values through the call chain.
+type requestLogFields struct {
+	mu    sync.Mutex
+	model string
+	jobID string
+}
+
+func (f *requestLogFields) setModel(model string) {
+	f.mu.Lock()
+	f.model = model
+	f.mu.Unlock()
+}
+
+func (f *requestLogFields) setJobID(jobID string) {
+	f.mu.Lock()
+	f.jobID = jobID
+	f.mu.Unlock()
+}
+
+// logFieldsFromContext returns the requestLogFields attached by
+// requestIDMiddleware, or a throwaway instance if none is present.
+func logFieldsFromContext(ctx context.Context) *requestLogFields {
+	if f, ok := ctx.Value(logFieldsKey{}).(*requestLogFields); ok {
+		return f
+	}
+	return &requestLogFields{}
+}
+
+// statusRecorder captures the status code written to an http.ResponseWriter
+// so the logging middleware can record it after the handler returns.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// requestIDMiddleware assigns (or propagates) an X-Request-ID and emits a
+// structured JSON access log line via log/slog once the request completes,
+// so operators can grep a single job_id/request_id across gateway and
+// backend logs.
+func requestIDMiddleware(logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get("X-Request-ID")
+		if requestID == "" {
+			requestID = uuid.New().String()
+		}
+		w.Header().Set("X-Request-ID", requestID)
+
+		fields := &requestLogFields{}
+		ctx := context.WithValue(r.Context(), requestIDKey{}, requestID)
+		ctx = context.WithValue(ctx, logFieldsKey{}, fields)
+		r = r.WithContext(ctx)
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(rec, r)
+
+		fields.mu.Lock()
+		model, jobID := fields.model, fields.jobID
+		fields.mu.Unlock()
+
+		logger.Info("request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"model", model,
+			"job_id", jobID,
+			"request_id", requestID,
+			"status", rec.status,
+			"duration_ms", time.Since(start).Milliseconds(),
+		)
+	})
+}
+
 func main() {
 	cfg := LoadConfig()
-	server := NewProxy1Server(cfg)
+	server, err := NewProxy1Server(cfg)
+	if err != nil {
+		log.Fatalf("failed to start gateway: %v", err)
+	}
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	go func() {
+		for range sighup {
+			if err := server.limiter.Reload(); err != nil {
+				log.Printf("failed to reload %s: %v", cfg.KeysConfigPath, err)
+				continue
+			}
+			log.Printf("reloaded rate limit config from %s", cfg.KeysConfigPath)
+		}
+	}()
 
 	r := mux.NewRouter()
 	r.HandleFunc("/v1/chat/completions", server.ChatCompletions).Methods("POST")
+	r.HandleFunc("/api/chat", server.OllamaChat).Methods("POST")
+	r.HandleFunc("/api/generate", server.OllamaGenerate).Methods("POST")
+	r.HandleFunc("/v1/messages", server.AnthropicMessages).Methods("POST")
 	r.HandleFunc("/v1/models", server.Models).Methods("GET")
+	r.HandleFunc("/v1/models/{id}", server.ModelDetail).Methods("GET")
 	r.HandleFunc("/v1/stats", server.BackendStats).Methods("GET")
+	r.HandleFunc("/metrics", server.Metrics).Methods("GET")
 
 	r.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
 	}).Methods("GET")
 
+	handler := requestIDMiddleware(logger, r)
+
 	port := getEnv("PORT", "8080")
 	log.Printf("Gateway listening on :%s", port)
-	log.Fatal(http.ListenAndServe(":"+port, r))
+	log.Fatal(http.ListenAndServe(":"+port, handler))
 }
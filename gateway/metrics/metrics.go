@@ -0,0 +1,329 @@
+// Package metrics is a minimal Prometheus-compatible metrics registry for
+// the gateway. It avoids pulling in the full client_golang dependency tree;
+// we only need counters and histograms rendered in the text exposition
+// format, so a small hand-rolled registry keeps things simple.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// defaultBuckets mirrors client_golang's DefBuckets, which covers sub-ms to
+// multi-second latencies reasonably well for an HTTP proxy.
+var defaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// Registry collects named counters and histograms and renders them in the
+// Prometheus text exposition format.
+type Registry struct {
+	mu         sync.Mutex
+	counters   map[string]*CounterVec
+	gauges     map[string]*GaugeVec
+	histograms map[string]*HistogramVec
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		counters:   make(map[string]*CounterVec),
+		gauges:     make(map[string]*GaugeVec),
+		histograms: make(map[string]*HistogramVec),
+	}
+}
+
+// Counter returns (creating if necessary) the named counter vector with the
+// given label names and help text.
+func (r *Registry) Counter(name, help string, labelNames ...string) *CounterVec {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c, ok := r.counters[name]; ok {
+		return c
+	}
+	c := &CounterVec{name: name, help: help, labelNames: labelNames, values: make(map[string]*CounterValue)}
+	r.counters[name] = c
+	return c
+}
+
+// Gauge returns (creating if necessary) the named gauge vector.
+func (r *Registry) Gauge(name, help string, labelNames ...string) *GaugeVec {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if g, ok := r.gauges[name]; ok {
+		return g
+	}
+	g := &GaugeVec{name: name, help: help, labelNames: labelNames, values: make(map[string]*GaugeValue)}
+	r.gauges[name] = g
+	return g
+}
+
+// Histogram returns (creating if necessary) the named histogram vector using
+// defaultBuckets.
+func (r *Registry) Histogram(name, help string, labelNames ...string) *HistogramVec {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if h, ok := r.histograms[name]; ok {
+		return h
+	}
+	h := &HistogramVec{name: name, help: help, labelNames: labelNames, buckets: defaultBuckets, values: make(map[string]*HistogramValue)}
+	r.histograms[name] = h
+	return h
+}
+
+// Render renders all registered metrics in Prometheus text exposition
+// format to w.
+func (r *Registry) Render(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make([]string, 0, len(r.counters)+len(r.gauges)+len(r.histograms))
+	for n := range r.counters {
+		names = append(names, "c:"+n)
+	}
+	for n := range r.gauges {
+		names = append(names, "g:"+n)
+	}
+	for n := range r.histograms {
+		names = append(names, "h:"+n)
+	}
+	sort.Strings(names)
+
+	for _, key := range names {
+		kind, name := key[:1], key[2:]
+		switch kind {
+		case "c":
+			if err := r.counters[name].writeTo(w); err != nil {
+				return err
+			}
+		case "g":
+			if err := r.gauges[name].writeTo(w); err != nil {
+				return err
+			}
+		case "h":
+			if err := r.histograms[name].writeTo(w); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func labelKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	parts := make([]string, len(names))
+	for i, n := range names {
+		v := ""
+		if i < len(values) {
+			v = values[i]
+		}
+		parts[i] = fmt.Sprintf("%s=%q", n, v)
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+type CounterValue struct {
+	mu     sync.Mutex
+	value  float64
+	labels []string
+}
+
+type CounterVec struct {
+	name       string
+	help       string
+	labelNames []string
+
+	mu     sync.Mutex
+	values map[string]*CounterValue
+}
+
+func (c *CounterVec) WithLabelValues(values ...string) *CounterValue {
+	key := labelKey(values)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.values[key]
+	if !ok {
+		v = &CounterValue{labels: append([]string{}, values...)}
+		c.values[key] = v
+	}
+	return v
+}
+
+func (v *CounterValue) Inc() {
+	v.Add(1)
+}
+
+func (v *CounterValue) Add(delta float64) {
+	v.mu.Lock()
+	v.value += delta
+	v.mu.Unlock()
+}
+
+func (c *CounterVec) writeTo(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name); err != nil {
+		return err
+	}
+	for _, v := range c.values {
+		v.mu.Lock()
+		_, err := fmt.Fprintf(w, "%s%s %g\n", c.name, formatLabels(c.labelNames, v.labels), v.value)
+		v.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type GaugeValue struct {
+	mu     sync.Mutex
+	value  float64
+	labels []string
+}
+
+type GaugeVec struct {
+	name       string
+	help       string
+	labelNames []string
+
+	mu     sync.Mutex
+	values map[string]*GaugeValue
+}
+
+func (g *GaugeVec) WithLabelValues(values ...string) *GaugeValue {
+	key := labelKey(values)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	v, ok := g.values[key]
+	if !ok {
+		v = &GaugeValue{labels: append([]string{}, values...)}
+		g.values[key] = v
+	}
+	return v
+}
+
+func (v *GaugeValue) Inc() { v.Add(1) }
+func (v *GaugeValue) Dec() { v.Add(-1) }
+
+func (v *GaugeValue) Add(delta float64) {
+	v.mu.Lock()
+	v.value += delta
+	v.mu.Unlock()
+}
+
+func (v *GaugeValue) Set(value float64) {
+	v.mu.Lock()
+	v.value = value
+	v.mu.Unlock()
+}
+
+func (g *GaugeVec) writeTo(w io.Writer) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", g.name, g.help, g.name); err != nil {
+		return err
+	}
+	for _, v := range g.values {
+		v.mu.Lock()
+		_, err := fmt.Fprintf(w, "%s%s %g\n", g.name, formatLabels(g.labelNames, v.labels), v.value)
+		v.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type HistogramValue struct {
+	mu           sync.Mutex
+	labels       []string
+	bucketCounts []uint64
+	sum          float64
+	count        uint64
+}
+
+type HistogramVec struct {
+	name       string
+	help       string
+	labelNames []string
+	buckets    []float64
+
+	mu     sync.Mutex
+	values map[string]*HistogramValue
+}
+
+func (h *HistogramVec) WithLabelValues(values ...string) *HistogramValue {
+	key := labelKey(values)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	v, ok := h.values[key]
+	if !ok {
+		v = &HistogramValue{labels: append([]string{}, values...), bucketCounts: make([]uint64, len(h.buckets))}
+		h.values[key] = v
+	}
+	return v
+}
+
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	v := h.WithLabelValues(labelValues...)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.sum += value
+	v.count++
+	for i, bound := range h.buckets {
+		if value <= bound {
+			v.bucketCounts[i]++
+		}
+	}
+}
+
+func (h *HistogramVec) writeTo(w io.Writer) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name); err != nil {
+		return err
+	}
+	for _, v := range h.values {
+		v.mu.Lock()
+		labels := formatLabels(h.labelNames, v.labels)
+		// bucketCounts[i] is already cumulative: Observe increments every
+		// bucket whose bound the value falls under, not just the first.
+		for i, bound := range h.buckets {
+			if _, err := fmt.Fprintf(w, "%s_bucket{le=%q%s} %d\n", h.name, fmt.Sprintf("%g", bound), stripBraces(labels), v.bucketCounts[i]); err != nil {
+				v.mu.Unlock()
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"%s} %d\n", h.name, stripBraces(labels), v.count); err != nil {
+			v.mu.Unlock()
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "%s_sum%s %g\n", h.name, labels, v.sum); err != nil {
+			v.mu.Unlock()
+			return err
+		}
+		_, err := fmt.Fprintf(w, "%s_count%s %d\n", h.name, labels, v.count)
+		v.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stripBraces turns "{a="b"}" into ",a=\"b\"" so it can be appended after a
+// fixed le= label, or "" into "" when there are no extra labels.
+func stripBraces(labels string) string {
+	if labels == "" {
+		return ""
+	}
+	return "," + strings.TrimSuffix(strings.TrimPrefix(labels, "{"), "}")
+}
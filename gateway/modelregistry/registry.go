@@ -0,0 +1,188 @@
+// Package modelregistry maintains the gateway's live view of which models
+// are available, by periodically polling each backend's tags endpoint and
+// caching the union. It also resolves static model aliases loaded from an
+// optional YAML file, so clients can request a familiar name (e.g.
+// "gpt-4o-mini") for whatever Ollama model it maps to.
+package modelregistry
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ModelInfo is the capability manifest for one model, as advertised by the
+// backend that serves it.
+type ModelInfo struct {
+	ID            string `json:"id"`
+	OwnedBy       string `json:"owned_by"`
+	ContextLength int    `json:"context_length,omitempty"`
+	SupportsTools bool   `json:"supports_tools,omitempty"`
+}
+
+// tagsResponse is the shape expected back from a backend's tags endpoint.
+type tagsResponse struct {
+	Models []struct {
+		Name          string `json:"name"`
+		OwnedBy       string `json:"owned_by"`
+		ContextLength int    `json:"context_length"`
+		SupportsTools bool   `json:"supports_tools"`
+	} `json:"models"`
+}
+
+// AliasConfig is the shape of models.yaml: a map of alias to canonical
+// model ID.
+type AliasConfig struct {
+	Aliases map[string]string `yaml:"aliases"`
+}
+
+// LoadAliasConfig reads and parses a models.yaml file. A missing file is
+// not an error; it just means no aliases are configured.
+func LoadAliasConfig(path string) (*AliasConfig, error) {
+	if path == "" {
+		return &AliasConfig{Aliases: map[string]string{}}, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &AliasConfig{Aliases: map[string]string{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cfg AliasConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if cfg.Aliases == nil {
+		cfg.Aliases = map[string]string{}
+	}
+	return &cfg, nil
+}
+
+// Registry holds the union of models advertised by every backend, along
+// with the backend URLs serving each one, refreshed periodically.
+type Registry struct {
+	tagsPath string
+	client   *http.Client
+	aliases  map[string]string
+
+	mu       sync.RWMutex
+	models   map[string]ModelInfo
+	backends map[string][]string
+}
+
+// NewRegistry builds a Registry. tagsPath is the path appended to each
+// backend's base URL to fetch its model manifest (defaulting to
+// "/api/tags"); aliasConfigPath points at an optional models.yaml.
+func NewRegistry(tagsPath, aliasConfigPath string) (*Registry, error) {
+	if tagsPath == "" {
+		tagsPath = "/api/tags"
+	}
+	aliasCfg, err := LoadAliasConfig(aliasConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	return &Registry{
+		tagsPath: tagsPath,
+		client:   &http.Client{Timeout: 5 * time.Second},
+		aliases:  aliasCfg.Aliases,
+		models:   make(map[string]ModelInfo),
+		backends: make(map[string][]string),
+	}, nil
+}
+
+// Start does an initial synchronous Refresh and then launches a background
+// loop that re-polls every interval. backendURLs is called fresh on every
+// tick so a changed backend pool is picked up without restarting the
+// registry.
+func (r *Registry) Start(backendURLs func() []string, interval time.Duration) {
+	r.Refresh(backendURLs())
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			r.Refresh(backendURLs())
+		}
+	}()
+}
+
+// Refresh re-polls every backend's tags endpoint and replaces the cached
+// model union. A backend that fails to respond simply contributes no
+// models this round instead of failing the whole refresh.
+func (r *Registry) Refresh(backendURLs []string) {
+	models := make(map[string]ModelInfo)
+	backends := make(map[string][]string)
+
+	for _, url := range backendURLs {
+		resp, err := r.client.Get(url + r.tagsPath)
+		if err != nil {
+			continue
+		}
+		var tags tagsResponse
+		err = json.NewDecoder(resp.Body).Decode(&tags)
+		resp.Body.Close()
+		if err != nil {
+			continue
+		}
+		for _, m := range tags.Models {
+			models[m.Name] = ModelInfo{
+				ID:            m.Name,
+				OwnedBy:       m.OwnedBy,
+				ContextLength: m.ContextLength,
+				SupportsTools: m.SupportsTools,
+			}
+			backends[m.Name] = append(backends[m.Name], url)
+		}
+	}
+
+	r.mu.Lock()
+	r.models = models
+	r.backends = backends
+	r.mu.Unlock()
+}
+
+// ResolveAlias maps a client-requested model name through the alias table;
+// a name with no configured alias passes through unchanged.
+func (r *Registry) ResolveAlias(model string) string {
+	if canonical, ok := r.aliases[model]; ok {
+		return canonical
+	}
+	return model
+}
+
+// Get returns the cached manifest for a canonical model ID.
+func (r *Registry) Get(id string) (ModelInfo, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	info, ok := r.models[id]
+	return info, ok
+}
+
+// BackendsFor returns the backend URLs currently advertising a canonical
+// model ID.
+func (r *Registry) BackendsFor(id string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]string(nil), r.backends[id]...)
+}
+
+// List returns every known model, sorted by ID.
+func (r *Registry) List() []ModelInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]ModelInfo, 0, len(r.models))
+	for _, m := range r.models {
+		out = append(out, m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
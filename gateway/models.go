@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/CyberGarden2025/ollama-ha-proxy/gateway/modelregistry"
+)
+
+// ModelRouter resolves a client-requested model (after alias expansion) to
+// its canonical ID and the backend URLs currently able to serve it, using
+// the live model registry.
+type ModelRouter struct {
+	registry *modelregistry.Registry
+}
+
+func newModelRouter(registry *modelregistry.Registry) *ModelRouter {
+	return &ModelRouter{registry: registry}
+}
+
+// ModelNotFoundError means the requested model isn't served by any
+// currently-known backend; Available lists what is, for a clean client
+// error message.
+type ModelNotFoundError struct {
+	Model     string
+	Available []string
+}
+
+func (e *ModelNotFoundError) Error() string {
+	return fmt.Sprintf("model %q not found, available models: %s", e.Model, strings.Join(e.Available, ", "))
+}
+
+// Resolve maps model to its canonical ID and the backend URLs serving it,
+// or a *ModelNotFoundError if no backend currently advertises it.
+func (m *ModelRouter) Resolve(model string) (canonicalModel string, backendURLs []string, err error) {
+	canonical := m.registry.ResolveAlias(model)
+	urls := m.registry.BackendsFor(canonical)
+	if len(urls) == 0 {
+		return "", nil, &ModelNotFoundError{Model: model, Available: m.modelIDs()}
+	}
+	return canonical, urls, nil
+}
+
+// CanonicalLabel resolves model to its canonical ID for use as a metrics
+// label value, or "unknown" if it isn't a model the registry currently
+// knows about. Metrics label maps never evict, so using the raw
+// client-supplied model string directly would let any caller grow them
+// without bound just by sending a new bogus value per request.
+func (m *ModelRouter) CanonicalLabel(model string) string {
+	canonical := m.registry.ResolveAlias(model)
+	if _, ok := m.registry.Get(canonical); !ok {
+		return "unknown"
+	}
+	return canonical
+}
+
+// List returns every known model's capability manifest.
+func (m *ModelRouter) List() []modelregistry.ModelInfo {
+	return m.registry.List()
+}
+
+// Get returns the capability manifest for a single canonical model ID.
+func (m *ModelRouter) Get(id string) (modelregistry.ModelInfo, bool) {
+	return m.registry.Get(id)
+}
+
+func (m *ModelRouter) modelIDs() []string {
+	list := m.registry.List()
+	ids := make([]string, len(list))
+	for i, info := range list {
+		ids[i] = info.ID
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (s *Proxy1Server) Models(w http.ResponseWriter, r *http.Request) {
+	if err := s.validateAuth(r); err != nil {
+		s.writeError(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	list := s.modelRouter.List()
+	data := make([]map[string]interface{}, len(list))
+	for i, info := range list {
+		data[i] = map[string]interface{}{
+			"id":       info.ID,
+			"object":   "model",
+			"created":  time.Now().Unix(),
+			"owned_by": info.OwnedBy,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"object": "list",
+		"data":   data,
+	})
+}
+
+// ModelDetail handles GET /v1/models/{id}, returning the model's cached
+// capability manifest (context length, tool support, owner).
+func (s *Proxy1Server) ModelDetail(w http.ResponseWriter, r *http.Request) {
+	if err := s.validateAuth(r); err != nil {
+		s.writeError(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	info, ok := s.modelRouter.Get(id)
+	if !ok {
+		s.writeError(w, fmt.Sprintf("model %q not found", id), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":             info.ID,
+		"object":         "model",
+		"owned_by":       info.OwnedBy,
+		"context_length": info.ContextLength,
+		"supports_tools": info.SupportsTools,
+	})
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// OllamaChatRequest is Ollama's native /api/chat request shape. Messages
+// reuse the same map[string]interface{} representation as
+// OpenAIChatRequest since both APIs use {"role": ..., "content": ...}.
+// Stream is a pointer because Ollama defaults to streaming when the field
+// is omitted, unlike OpenAI's default-to-false.
+type OllamaChatRequest struct {
+	Model    string                   `json:"model"`
+	Messages []map[string]interface{} `json:"messages"`
+	Stream   *bool                    `json:"stream,omitempty"`
+	Options  map[string]interface{}   `json:"options,omitempty"`
+}
+
+func (r OllamaChatRequest) stream() bool {
+	return r.Stream == nil || *r.Stream
+}
+
+// OllamaGenerateRequest is Ollama's native /api/generate request shape: a
+// single prompt instead of a message list.
+type OllamaGenerateRequest struct {
+	Model   string                 `json:"model"`
+	Prompt  string                 `json:"prompt"`
+	Stream  *bool                  `json:"stream,omitempty"`
+	Options map[string]interface{} `json:"options,omitempty"`
+}
+
+func (r OllamaGenerateRequest) stream() bool {
+	return r.Stream == nil || *r.Stream
+}
+
+// applyOllamaOptions copies the options a native Ollama request maps
+// directly onto the internal BackendJobRequest.Options fields that
+// createBackendJob already knows how to build.
+func applyOllamaOptions(req *OpenAIChatRequest, options map[string]interface{}) {
+	if options == nil {
+		return
+	}
+	if v, ok := options["temperature"].(float64); ok {
+		req.Temperature = v
+	}
+	if v, ok := options["top_p"].(float64); ok {
+		req.TopP = v
+	}
+	if v, ok := options["num_predict"].(float64); ok {
+		req.MaxTokens = int(v)
+	}
+}
+
+func ollamaChatToOpenAI(req OllamaChatRequest) OpenAIChatRequest {
+	out := OpenAIChatRequest{
+		Model:    req.Model,
+		Messages: req.Messages,
+		Stream:   req.stream(),
+	}
+	applyOllamaOptions(&out, req.Options)
+	return out
+}
+
+func ollamaGenerateToOpenAI(req OllamaGenerateRequest) OpenAIChatRequest {
+	out := OpenAIChatRequest{
+		Model:    req.Model,
+		Messages: []map[string]interface{}{{"role": "user", "content": req.Prompt}},
+		Stream:   req.stream(),
+	}
+	applyOllamaOptions(&out, req.Options)
+	return out
+}
+
+// AnthropicMessage is a single message in an Anthropic Messages API
+// request. Content is either a plain string or a list of content blocks;
+// see anthropicContentText.
+type AnthropicMessage struct {
+	Role    string      `json:"role"`
+	Content interface{} `json:"content"`
+}
+
+// AnthropicMessagesRequest is the Anthropic Messages API request shape.
+// Unlike OpenAI/Ollama, the system prompt is a top-level field rather than
+// a message with role "system".
+type AnthropicMessagesRequest struct {
+	Model       string             `json:"model"`
+	Messages    []AnthropicMessage `json:"messages"`
+	System      string             `json:"system,omitempty"`
+	MaxTokens   int                `json:"max_tokens"`
+	Temperature float64            `json:"temperature,omitempty"`
+	TopP        float64            `json:"top_p,omitempty"`
+	Stream      bool               `json:"stream,omitempty"`
+}
+
+// anthropicContentText flattens an Anthropic message's content into a
+// single string: a plain string passes through unchanged, and a list of
+// content blocks has its "text"-type blocks concatenated (other block
+// types, e.g. images, are dropped since the backend only understands
+// plain text).
+func anthropicContentText(content interface{}) string {
+	switch c := content.(type) {
+	case string:
+		return c
+	case []interface{}:
+		var sb strings.Builder
+		for _, block := range c {
+			m, ok := block.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if m["type"] != "text" {
+				continue
+			}
+			if text, ok := m["text"].(string); ok {
+				sb.WriteString(text)
+			}
+		}
+		return sb.String()
+	default:
+		return ""
+	}
+}
+
+func anthropicToOpenAI(req AnthropicMessagesRequest) OpenAIChatRequest {
+	messages := make([]map[string]interface{}, 0, len(req.Messages)+1)
+	if req.System != "" {
+		messages = append(messages, map[string]interface{}{"role": "system", "content": req.System})
+	}
+	for _, m := range req.Messages {
+		messages = append(messages, map[string]interface{}{
+			"role":    m.Role,
+			"content": anthropicContentText(m.Content),
+		})
+	}
+	return OpenAIChatRequest{
+		Model:       req.Model,
+		Messages:    messages,
+		Stream:      req.Stream,
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+		MaxTokens:   req.MaxTokens,
+	}
+}
+
+// OllamaChat handles Ollama's native /api/chat, translating to the
+// internal request shape and streaming back NDJSON.
+func (s *Proxy1Server) OllamaChat(w http.ResponseWriter, r *http.Request) {
+	if err := s.validateAuth(r); err != nil {
+		s.writeError(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var req OllamaChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	openAIReq := ollamaChatToOpenAI(req)
+	s.dispatchChat(w, r, openAIReq, newOllamaEncoder(openAIReq.Model))
+}
+
+// OllamaGenerate handles Ollama's native /api/generate.
+func (s *Proxy1Server) OllamaGenerate(w http.ResponseWriter, r *http.Request) {
+	if err := s.validateAuth(r); err != nil {
+		s.writeError(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var req OllamaGenerateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	openAIReq := ollamaGenerateToOpenAI(req)
+	s.dispatchChat(w, r, openAIReq, newOllamaEncoder(openAIReq.Model))
+}
+
+// AnthropicMessages handles the Anthropic Messages API's /v1/messages.
+func (s *Proxy1Server) AnthropicMessages(w http.ResponseWriter, r *http.Request) {
+	if err := s.validateAuth(r); err != nil {
+		s.writeError(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var req AnthropicMessagesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	openAIReq := anthropicToOpenAI(req)
+	s.dispatchChat(w, r, openAIReq, newAnthropicEncoder(openAIReq.Model))
+}
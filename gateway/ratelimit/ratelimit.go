@@ -0,0 +1,322 @@
+// Package ratelimit implements per-API-key and per-IP token-bucket rate
+// limiting plus a global in-flight concurrency gate for the gateway. Tiers
+// (requests/minute, tokens/minute, concurrency) are looked up from an
+// optional YAML file and fall back to a single default tier for unknown
+// keys/IPs.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Tier describes the quota granted to a single API key or IP address.
+type Tier struct {
+	RPM         int `yaml:"rpm"`
+	TPM         int `yaml:"tpm"`
+	Concurrency int `yaml:"concurrency"`
+}
+
+// Config is the shape of keys.yaml: a map of API key to Tier.
+type Config struct {
+	Keys map[string]Tier `yaml:"keys"`
+}
+
+// LoadConfig reads and parses a keys.yaml file. A missing file is not an
+// error; it just means every caller uses the default tier.
+func LoadConfig(path string) (*Config, error) {
+	if path == "" {
+		return &Config{Keys: map[string]Tier{}}, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Config{Keys: map[string]Tier{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if cfg.Keys == nil {
+		cfg.Keys = map[string]Tier{}
+	}
+	return &cfg, nil
+}
+
+// QuotaExceededError means the caller's per-key/IP rate limit was hit; the
+// gateway should respond 429 with a Retry-After header.
+type QuotaExceededError struct {
+	RetryAfter time.Duration
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("rate limit exceeded, retry after %s", e.RetryAfter)
+}
+
+// OverloadedError means the global concurrency queue timed out; the gateway
+// should respond 503.
+type OverloadedError struct{}
+
+func (e *OverloadedError) Error() string { return "admission queue timeout" }
+
+// bucket is a simple token bucket refilled continuously at a fixed
+// per-second rate, used for both the requests/minute and tokens/minute
+// limits.
+type bucket struct {
+	mu           sync.Mutex
+	capacity     float64
+	tokens       float64
+	refillPerSec float64
+	last         time.Time
+}
+
+func newBucket(perMinute int) *bucket {
+	capacity := float64(perMinute)
+	return &bucket{
+		capacity:     capacity,
+		tokens:       capacity,
+		refillPerSec: capacity / 60.0,
+		last:         time.Now(),
+	}
+}
+
+// allow attempts to withdraw n tokens, refilling first. perMinute <= 0
+// means unlimited.
+func (b *bucket) allow(n float64) bool {
+	if b == nil || b.capacity <= 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	elapsed := now.Sub(b.last).Seconds()
+	b.last = now
+	b.tokens = min64(b.capacity, b.tokens+elapsed*b.refillPerSec)
+
+	if b.tokens < n {
+		return false
+	}
+	b.tokens -= n
+	return true
+}
+
+func min64(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// caller bundles the per-key/IP buckets and its own concurrency semaphore.
+// lastUsed (unix nanos, atomic) is touched on every Admit call so the idle
+// sweep in Manager.sweep can tell which callers haven't been seen recently.
+type caller struct {
+	reqBucket   *bucket
+	tokenBucket *bucket
+	sem         chan struct{}
+	lastUsed    atomic.Int64
+}
+
+func newCaller(tier Tier) *caller {
+	concurrency := tier.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1 << 20 // effectively unlimited
+	}
+	c := &caller{
+		reqBucket:   newBucket(tier.RPM),
+		tokenBucket: newBucket(tier.TPM),
+		sem:         make(chan struct{}, concurrency),
+	}
+	c.lastUsed.Store(time.Now().UnixNano())
+	return c
+}
+
+// Manager admits requests against per-API-key/IP quotas and a global
+// concurrency cap with a bounded wait queue.
+type Manager struct {
+	defaultTier  Tier
+	queueTimeout time.Duration
+	configPath   string
+
+	mu      sync.RWMutex
+	tiers   map[string]Tier
+	callers map[string]*caller
+
+	global chan struct{}
+
+	stopOnce sync.Once
+	stopCh   chan struct{}
+}
+
+// NewManager builds a Manager. globalConcurrency bounds total in-flight
+// requests across all keys/IPs; queueTimeout bounds how long a request
+// waits for a global concurrency slot before failing with
+// OverloadedError.
+func NewManager(defaultTier Tier, globalConcurrency int, queueTimeout time.Duration, configPath string) (*Manager, error) {
+	m := &Manager{
+		defaultTier:  defaultTier,
+		queueTimeout: queueTimeout,
+		configPath:   configPath,
+		callers:      make(map[string]*caller),
+		global:       make(chan struct{}, maxInt(globalConcurrency, 1)),
+		stopCh:       make(chan struct{}),
+	}
+	if err := m.Reload(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Start launches a background loop that evicts callers idle for longer
+// than idleTTL, checked every interval. Without this, Manager.callers (keyed
+// by API key or "ip:"+ip) grows without bound: an anonymous client can mint
+// a fresh per-IP entry forever. Call Stop to shut the loop down.
+func (m *Manager) Start(idleTTL, interval time.Duration) {
+	if idleTTL <= 0 || interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.sweep(idleTTL)
+			case <-m.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the background idle-eviction loop started by Start.
+func (m *Manager) Stop() {
+	m.stopOnce.Do(func() {
+		close(m.stopCh)
+	})
+}
+
+// sweep removes every caller untouched for longer than idleTTL and not
+// currently holding any in-flight slots, so a request that raced the sweep
+// isn't evicted out from under itself.
+func (m *Manager) sweep(idleTTL time.Duration) {
+	cutoff := time.Now().Add(-idleTTL).UnixNano()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, c := range m.callers {
+		if len(c.sem) == 0 && c.lastUsed.Load() < cutoff {
+			delete(m.callers, id)
+		}
+	}
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// Reload re-reads the YAML config from disk. Existing per-caller buckets
+// for keys whose tier changed keep their current fill level; only newly
+// seen keys get a fresh bucket sized to the new tier. This is intentionally
+// simple: a SIGHUP is an infrequent, operator-driven event, not a hot path.
+func (m *Manager) Reload() error {
+	cfg, err := LoadConfig(m.configPath)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tiers = cfg.Keys
+	return nil
+}
+
+func (m *Manager) tierFor(key string) Tier {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if tier, ok := m.tiers[key]; ok {
+		return tier
+	}
+	return m.defaultTier
+}
+
+func (m *Manager) callerFor(id string, tier Tier) *caller {
+	m.mu.RLock()
+	c, ok := m.callers[id]
+	m.mu.RUnlock()
+	if ok {
+		c.lastUsed.Store(time.Now().UnixNano())
+		return c
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if c, ok := m.callers[id]; ok {
+		c.lastUsed.Store(time.Now().UnixNano())
+		return c
+	}
+	c = newCaller(tier)
+	m.callers[id] = c
+	return c
+}
+
+// Admit checks the per-key and per-IP quotas and, on success, acquires a
+// global concurrency slot (waiting up to queueTimeout). On success it
+// returns a release func the caller must invoke when the request finishes.
+// estimatedTokens is the request's estimated token cost, debited from the
+// tokens/minute bucket (the gateway uses MaxTokens as the estimator).
+func (m *Manager) Admit(ctx context.Context, apiKey, ip string, estimatedTokens int) (release func(), err error) {
+	id := apiKey
+	if id == "" {
+		id = "ip:" + ip
+	}
+	tier := m.tierFor(apiKey)
+	c := m.callerFor(id, tier)
+
+	if !c.reqBucket.allow(1) {
+		return nil, &QuotaExceededError{RetryAfter: time.Second * time.Duration(maxInt(1, 60/maxInt(tier.RPM, 1)))}
+	}
+	if !c.tokenBucket.allow(float64(estimatedTokens)) {
+		return nil, &QuotaExceededError{RetryAfter: time.Second}
+	}
+
+	select {
+	case c.sem <- struct{}{}:
+	case <-time.After(m.queueTimeout):
+		return nil, &OverloadedError{}
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	select {
+	case m.global <- struct{}{}:
+	case <-time.After(m.queueTimeout):
+		<-c.sem
+		return nil, &OverloadedError{}
+	case <-ctx.Done():
+		<-c.sem
+		return nil, ctx.Err()
+	}
+
+	released := false
+	release = func() {
+		if released {
+			return
+		}
+		released = true
+		<-c.sem
+		<-m.global
+	}
+	return release, nil
+}
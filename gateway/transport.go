@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// BackendEvent is a single item delivered by a BackendTransport. Chunk is
+// non-nil for a new chunk; Status carries the job's status whenever the
+// transport observes it (every poll response, or once at stream end for the
+// SSE transport). Err is set when the job can no longer make progress and
+// the channel is about to close.
+type BackendEvent struct {
+	Chunk  *BackendChunkData
+	Status string
+	Err    error
+}
+
+// BackendTransport delivers BackendChunkData for a job starting after
+// lastSeq, closing the returned channel once the job reaches a terminal
+// state, ctx is cancelled, or an unrecoverable error occurs.
+type BackendTransport interface {
+	Stream(ctx context.Context, model, jobID string, lastSeq int) <-chan BackendEvent
+}
+
+// pollTransport is the original transport: it repeatedly GETs
+// /jobs/{id}/events?from_seq= on a fixed interval with exponential backoff
+// on error.
+type pollTransport struct {
+	s *Proxy1Server
+}
+
+func newPollTransport(s *Proxy1Server) *pollTransport {
+	return &pollTransport{s: s}
+}
+
+func (t *pollTransport) Stream(ctx context.Context, model, jobID string, lastSeq int) <-chan BackendEvent {
+	out := make(chan BackendEvent, 8)
+	go func() {
+		defer close(out)
+		retryDelay := t.s.config.RetryBackoffInitMS
+
+		for {
+			select {
+			case <-ctx.Done():
+				out <- BackendEvent{Err: ctx.Err()}
+				return
+			default:
+			}
+
+			events, err := t.s.pollBackendEvents(ctx, model, jobID, lastSeq)
+			if err != nil {
+				log.Printf("Poll error for job %s: %v", jobID, err)
+				select {
+				case <-time.After(time.Duration(retryDelay) * time.Millisecond):
+				case <-ctx.Done():
+					out <- BackendEvent{Err: ctx.Err()}
+					return
+				}
+				retryDelay = min(retryDelay*2, t.s.config.RetryBackoffMaxMS)
+				continue
+			}
+			retryDelay = t.s.config.RetryBackoffInitMS
+
+			done := false
+			for i := range events.Chunks {
+				chunk := events.Chunks[i]
+				if chunk.Seq <= lastSeq {
+					continue
+				}
+				lastSeq = chunk.Seq
+				out <- BackendEvent{Chunk: &chunk, Status: events.Status}
+				if chunk.Done {
+					done = true
+				}
+			}
+			if done {
+				return
+			}
+
+			if events.Status == "completed" || events.Status == "failed" || events.Status == "cancelled" {
+				out <- BackendEvent{Status: events.Status}
+				return
+			}
+
+			select {
+			case <-time.After(time.Duration(t.s.config.PollIntervalMS) * time.Millisecond):
+			case <-ctx.Done():
+				out <- BackendEvent{Err: ctx.Err()}
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// streamUnsupportedError marks a /stream response that the backend doesn't
+// implement (404/501), so fallbackTransport can drop to polling instead of
+// retrying forever against an endpoint that will never work.
+type streamUnsupportedError struct{ status int }
+
+func (e *streamUnsupportedError) Error() string {
+	return fmt.Sprintf("backend stream endpoint unsupported (status %d)", e.status)
+}
+
+// sseTransport opens one long-lived SSE connection per job against
+// /jobs/{id}/stream and demuxes BackendChunkData events into a channel,
+// reconnecting with Last-Event-ID on disconnect.
+type sseTransport struct {
+	s *Proxy1Server
+}
+
+func newSSETransport(s *Proxy1Server) *sseTransport {
+	return &sseTransport{s: s}
+}
+
+func (t *sseTransport) Stream(ctx context.Context, model, jobID string, lastSeq int) <-chan BackendEvent {
+	out := make(chan BackendEvent, 8)
+	go func() {
+		defer close(out)
+		retryDelay := t.s.config.RetryBackoffInitMS
+
+		for {
+			select {
+			case <-ctx.Done():
+				out <- BackendEvent{Err: ctx.Err()}
+				return
+			default:
+			}
+
+			done, err := t.connectOnce(ctx, model, jobID, &lastSeq, out)
+			if done {
+				return
+			}
+			if err != nil {
+				if _, ok := err.(*streamUnsupportedError); ok {
+					out <- BackendEvent{Err: err}
+					return
+				}
+				log.Printf("Stream error for job %s: %v", jobID, err)
+			}
+
+			select {
+			case <-time.After(time.Duration(retryDelay) * time.Millisecond):
+			case <-ctx.Done():
+				out <- BackendEvent{Err: ctx.Err()}
+				return
+			}
+			retryDelay = min(retryDelay*2, t.s.config.RetryBackoffMaxMS)
+		}
+	}()
+	return out
+}
+
+// connectOnce opens a single SSE connection and streams chunks into out
+// until the connection drops or the job reaches a terminal state. done is
+// true once the job is finished and the caller should stop reconnecting.
+func (t *sseTransport) connectOnce(ctx context.Context, model, jobID string, lastSeq *int, out chan<- BackendEvent) (done bool, err error) {
+	backend, err := t.s.pool.BackendForJob(jobID)
+	if err != nil {
+		return false, err
+	}
+	url := fmt.Sprintf("%s/jobs/%s/stream", backend.URL, jobID)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if *lastSeq >= 0 {
+		req.Header.Set("Last-Event-ID", strconv.Itoa(*lastSeq))
+	}
+
+	resp, err := t.s.httpClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusNotImplemented {
+		return false, &streamUnsupportedError{status: resp.StatusCode}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("backend stream status: %d", resp.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var dataLine string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "data:"):
+			dataLine = strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		case line == "" && dataLine != "":
+			var chunk BackendChunkData
+			if jsonErr := json.Unmarshal([]byte(dataLine), &chunk); jsonErr == nil {
+				if chunk.Seq > *lastSeq {
+					*lastSeq = chunk.Seq
+					status := ""
+					if chunk.Done {
+						status = "completed"
+						if chunk.Error != "" {
+							status = "failed"
+						}
+					}
+					out <- BackendEvent{Chunk: &chunk, Status: status}
+					if chunk.Done {
+						return true, nil
+					}
+				}
+			}
+			dataLine = ""
+		}
+	}
+	if scanErr := scanner.Err(); scanErr != nil {
+		return false, scanErr
+	}
+	return false, nil
+}
+
+// fallbackTransport tries SSE streaming first and drops to polling
+// permanently once the backend signals it doesn't support /stream (404 or
+// 501), so later jobs on the same Proxy1Server skip the failed attempt.
+type fallbackTransport struct {
+	sse  *sseTransport
+	poll *pollTransport
+
+	unsupported atomic.Bool
+}
+
+func newFallbackTransport(s *Proxy1Server) *fallbackTransport {
+	return &fallbackTransport{sse: newSSETransport(s), poll: newPollTransport(s)}
+}
+
+func (t *fallbackTransport) Stream(ctx context.Context, model, jobID string, lastSeq int) <-chan BackendEvent {
+	if t.unsupported.Load() {
+		return t.poll.Stream(ctx, model, jobID, lastSeq)
+	}
+
+	out := make(chan BackendEvent, 8)
+	go func() {
+		defer close(out)
+		first := true
+		for ev := range t.sse.Stream(ctx, model, jobID, lastSeq) {
+			if first {
+				first = false
+				if _, ok := ev.Err.(*streamUnsupportedError); ok {
+					t.unsupported.Store(true)
+					for pollEv := range t.poll.Stream(ctx, model, jobID, lastSeq) {
+						out <- pollEv
+					}
+					return
+				}
+			}
+			out <- ev
+		}
+	}()
+	return out
+}
@@ -3,7 +3,9 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"compress/zlib"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -13,6 +15,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -24,6 +27,7 @@ type JobStatus string
 
 const (
 	StatusQueued    JobStatus = "queued"
+	StatusScheduled JobStatus = "scheduled"
 	StatusRunning   JobStatus = "running"
 	StatusCompleted JobStatus = "completed"
 	StatusFailed    JobStatus = "failed"
@@ -31,10 +35,15 @@ const (
 )
 
 type JobRequest struct {
-	Model    string                   `json:"model"`
-	Messages []map[string]interface{} `json:"messages,omitempty"`
-	Prompt   string                   `json:"prompt,omitempty"`
-	Options  map[string]interface{}   `json:"options,omitempty"`
+	Model             string                   `json:"model"`
+	Messages          []map[string]interface{} `json:"messages,omitempty"`
+	Prompt            string                   `json:"prompt,omitempty"`
+	Options           map[string]interface{}   `json:"options,omitempty"`
+	AllowPartialRetry bool                     `json:"allow_partial_retry,omitempty"`
+	Priority          string                   `json:"priority,omitempty"`
+	// RunAt, if set (RFC3339), defers dispatch until that time instead of
+	// enqueueing the job immediately; see Storage.ScheduleJob.
+	RunAt string `json:"run_at,omitempty"`
 }
 
 type JobResponse struct {
@@ -48,6 +57,12 @@ type ChunkData struct {
 	Done         bool   `json:"done"`
 	FinishReason string `json:"finish_reason,omitempty"`
 	Error        string `json:"error,omitempty"`
+	// DeltaZ and DeltaEnc hold Delta zlib-compressed (base64-encoded) and
+	// a marker identifying the encoding, used only for the copy persisted
+	// in job:{id}:chunks; GetChunks always returns Delta inflated and
+	// these cleared. See Storage.AddChunk.
+	DeltaZ   string `json:"delta_z,omitempty"`
+	DeltaEnc string `json:"delta_enc,omitempty"`
 }
 
 type EventsResponse struct {
@@ -63,11 +78,17 @@ type StatusResponse struct {
 }
 
 type JobMeta struct {
-	Status      JobStatus `json:"status"`
-	Model       string    `json:"model"`
-	CreatedAt   string    `json:"created_at"`
-	CompletedAt string    `json:"completed_at,omitempty"`
-	Error       string    `json:"error,omitempty"`
+	Status            JobStatus `json:"status"`
+	Model             string    `json:"model"`
+	CreatedAt         string    `json:"created_at"`
+	CompletedAt       string    `json:"completed_at,omitempty"`
+	Error             string    `json:"error,omitempty"`
+	Attempts          int       `json:"attempts,omitempty"`
+	LastAttemptAt     string    `json:"last_attempt_at,omitempty"`
+	NextAttemptAt     string    `json:"next_attempt_at,omitempty"`
+	AllowPartialRetry bool      `json:"allow_partial_retry,omitempty"`
+	Priority          string    `json:"priority,omitempty"`
+	ScheduledAt       string    `json:"scheduled_at,omitempty"`
 }
 
 type OllamaStreamResponse struct {
@@ -90,14 +111,49 @@ type OllamaStreamResponse struct {
 type Storage struct {
 	rdb *redis.Client
 	ctx context.Context
+
+	// compressionThreshold is the marshaled-byte size above which large
+	// meta fields (messages, options) and chunk deltas are zlib-compressed
+	// before being written to Redis. See StoreLargeField and AddChunk.
+	compressionThreshold int
+	compressionStats     compressionStats
+}
+
+// compressionStats tallies this process's transparent-compression
+// activity, exposed via GetWorkerStats so operators can see how much
+// Redis memory it's actually saving.
+type compressionStats struct {
+	count           int64
+	originalBytes   int64
+	compressedBytes int64
 }
 
-func NewStorage(redisURL string) (*Storage, error) {
+func (c *compressionStats) record(original, compressed int) {
+	atomic.AddInt64(&c.count, 1)
+	atomic.AddInt64(&c.originalBytes, int64(original))
+	atomic.AddInt64(&c.compressedBytes, int64(compressed))
+}
+
+func (c *compressionStats) snapshot() map[string]int64 {
+	return map[string]int64{
+		"count":            atomic.LoadInt64(&c.count),
+		"original_bytes":   atomic.LoadInt64(&c.originalBytes),
+		"compressed_bytes": atomic.LoadInt64(&c.compressedBytes),
+	}
+}
+
+const defaultCompressionThreshold = 8 * 1024
+
+func NewStorage(redisURL string, compressionThreshold int) (*Storage, error) {
 	opt, err := redis.ParseURL(redisURL)
 	if err != nil {
 		return nil, err
 	}
 
+	if compressionThreshold <= 0 {
+		compressionThreshold = defaultCompressionThreshold
+	}
+
 	rdb := redis.NewClient(opt)
 	ctx := context.Background()
 
@@ -105,15 +161,18 @@ func NewStorage(redisURL string) (*Storage, error) {
 		return nil, err
 	}
 
-	return &Storage{rdb: rdb, ctx: ctx}, nil
+	return &Storage{rdb: rdb, ctx: ctx, compressionThreshold: compressionThreshold}, nil
 }
 
 func (s *Storage) CreateJob(jobID string, meta JobMeta) error {
 	key := fmt.Sprintf("job:%s:meta", jobID)
 	data := map[string]interface{}{
-		"status":     string(meta.Status),
-		"model":      meta.Model,
-		"created_at": meta.CreatedAt,
+		"status":              string(meta.Status),
+		"model":               meta.Model,
+		"created_at":          meta.CreatedAt,
+		"allow_partial_retry": boolToRedisFlag(meta.AllowPartialRetry),
+		"priority":            string(normalizePriority(meta.Priority)),
+		"scheduled_at":        meta.ScheduledAt,
 	}
 	return s.rdb.HSet(s.ctx, key, data).Err()
 }
@@ -128,16 +187,102 @@ func (s *Storage) GetJobMeta(jobID string) (*JobMeta, error) {
 		return nil, fmt.Errorf("job not found")
 	}
 
+	attempts, _ := strconv.Atoi(result["attempts"])
+
 	meta := &JobMeta{
-		Status:      JobStatus(result["status"]),
-		Model:       result["model"],
-		CreatedAt:   result["created_at"],
-		CompletedAt: result["completed_at"],
-		Error:       result["error"],
+		Status:            JobStatus(result["status"]),
+		Model:             result["model"],
+		CreatedAt:         result["created_at"],
+		CompletedAt:       result["completed_at"],
+		Error:             result["error"],
+		Attempts:          attempts,
+		LastAttemptAt:     result["last_attempt_at"],
+		NextAttemptAt:     result["next_attempt_at"],
+		AllowPartialRetry: result["allow_partial_retry"] == "1",
+		Priority:          result["priority"],
+		ScheduledAt:       result["scheduled_at"],
 	}
 	return meta, nil
 }
 
+func boolToRedisFlag(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+func zlibCompress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		zw.Close()
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func zlibDecompress(data []byte) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+// StoreLargeField HSets value under field on job:{id}:meta, transparently
+// zlib-compressing it into field+"_z" (with a field+"_enc"="zlib" marker)
+// when it exceeds compressionThreshold. LoadLargeField is the inverse.
+func (s *Storage) StoreLargeField(jobID, field string, value []byte) error {
+	key := fmt.Sprintf("job:%s:meta", jobID)
+	if len(value) <= s.compressionThreshold {
+		return s.rdb.HSet(s.ctx, key, field, value).Err()
+	}
+
+	compressed, err := zlibCompress(value)
+	if err != nil {
+		return s.rdb.HSet(s.ctx, key, field, value).Err()
+	}
+	s.compressionStats.record(len(value), len(compressed))
+	return s.rdb.HSet(s.ctx, key, field+"_z", compressed, field+"_enc", "zlib").Err()
+}
+
+// LoadLargeField reads field back from job:{id}:meta, inflating it if
+// StoreLargeField compressed it. Returns "" if the field was never set.
+func (s *Storage) LoadLargeField(jobID, field string) (string, error) {
+	key := fmt.Sprintf("job:%s:meta", jobID)
+
+	enc, err := s.rdb.HGet(s.ctx, key, field+"_enc").Result()
+	if err != nil && err != redis.Nil {
+		return "", err
+	}
+
+	if enc == "zlib" {
+		compressed, err := s.rdb.HGet(s.ctx, key, field+"_z").Bytes()
+		if err == redis.Nil {
+			return "", nil
+		}
+		if err != nil {
+			return "", err
+		}
+		data, err := zlibDecompress(compressed)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+
+	val, err := s.rdb.HGet(s.ctx, key, field).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return val, err
+}
+
 func (s *Storage) UpdateJobStatus(jobID string, status JobStatus, completedAt string, errorMsg string) error {
 	key := fmt.Sprintf("job:%s:meta", jobID)
 	data := map[string]interface{}{
@@ -152,13 +297,46 @@ func (s *Storage) UpdateJobStatus(jobID string, status JobStatus, completedAt st
 	return s.rdb.HSet(s.ctx, key, data).Err()
 }
 
+// AddChunk persists chunk and publishes it for any live StreamJob
+// subscriber. Subscribers always get the chunk uncompressed (compression
+// is purely a storage-economy measure); the list entry written to
+// job:{id}:chunks has its Delta zlib-compressed into DeltaZ/DeltaEnc when
+// it's larger than compressionThreshold, which GetChunks inflates back.
 func (s *Storage) AddChunk(jobID string, chunk ChunkData) error {
 	key := fmt.Sprintf("job:%s:chunks", jobID)
 	data, err := json.Marshal(chunk)
 	if err != nil {
 		return err
 	}
-	return s.rdb.RPush(s.ctx, key, string(data)).Err()
+	if err := s.rdb.Publish(s.ctx, chunkChannelKey(jobID), string(data)).Err(); err != nil {
+		return err
+	}
+
+	stored := data
+	if len(chunk.Delta) > s.compressionThreshold {
+		if compressed, cErr := zlibCompress([]byte(chunk.Delta)); cErr == nil {
+			s.compressionStats.record(len(chunk.Delta), len(compressed))
+			compact := chunk
+			compact.Delta = ""
+			compact.DeltaZ = base64.StdEncoding.EncodeToString(compressed)
+			compact.DeltaEnc = "zlib"
+			if encoded, mErr := json.Marshal(compact); mErr == nil {
+				stored = encoded
+			}
+		}
+	}
+
+	return s.rdb.RPush(s.ctx, key, string(stored)).Err()
+}
+
+func chunkChannelKey(jobID string) string {
+	return fmt.Sprintf("job:%s:pub", jobID)
+}
+
+// SubscribeChunks subscribes to jobID's chunk pub/sub channel, which
+// AddChunk publishes to every time a chunk is persisted.
+func (s *Storage) SubscribeChunks(jobID string) *redis.PubSub {
+	return s.rdb.Subscribe(s.ctx, chunkChannelKey(jobID))
 }
 
 func (s *Storage) GetChunks(jobID string, fromSeq int) ([]ChunkData, error) {
@@ -174,6 +352,15 @@ func (s *Storage) GetChunks(jobID string, fromSeq int) ([]ChunkData, error) {
 		if err := json.Unmarshal([]byte(item), &chunk); err != nil {
 			continue
 		}
+		if chunk.DeltaEnc == "zlib" {
+			if raw, dErr := base64.StdEncoding.DecodeString(chunk.DeltaZ); dErr == nil {
+				if inflated, iErr := zlibDecompress(raw); iErr == nil {
+					chunk.Delta = string(inflated)
+				}
+			}
+			chunk.DeltaZ = ""
+			chunk.DeltaEnc = ""
+		}
 		if chunk.Seq > fromSeq {
 			chunks = append(chunks, chunk)
 			if len(chunks) >= 1000 {
@@ -207,28 +394,308 @@ func (s *Storage) SetTTL(jobID string, ttl time.Duration) error {
 	return nil
 }
 
+// Priority is a job's scheduling class. Interactive jobs are served
+// preferentially over normal and batch work via a weighted round-robin,
+// so a flood of batch requests can't starve interactive users.
+type Priority string
+
+const (
+	PriorityInteractive Priority = "interactive"
+	PriorityNormal      Priority = "normal"
+	PriorityBatch       Priority = "batch"
+)
+
+// priorityOrder is the strict fallback scan order used when the
+// weighted round-robin's preferred queue is empty.
+var priorityOrder = []Priority{PriorityInteractive, PriorityNormal, PriorityBatch}
+
+// weightedPrioritySequence implements the 8:4:1 weighted round-robin:
+// over any 13-tick window, interactive is polled 8 times, normal 4,
+// batch once.
+var weightedPrioritySequence = buildWeightedPrioritySequence()
+
+func buildWeightedPrioritySequence() []Priority {
+	seq := make([]Priority, 0, 13)
+	for i := 0; i < 8; i++ {
+		seq = append(seq, PriorityInteractive)
+	}
+	for i := 0; i < 4; i++ {
+		seq = append(seq, PriorityNormal)
+	}
+	seq = append(seq, PriorityBatch)
+	return seq
+}
+
+// normalizePriority maps an arbitrary client-supplied string to a known
+// Priority, defaulting to normal.
+func normalizePriority(p string) Priority {
+	switch Priority(p) {
+	case PriorityInteractive, PriorityBatch:
+		return Priority(p)
+	default:
+		return PriorityNormal
+	}
+}
+
+func pendingQueueKeyFor(p Priority) string {
+	switch p {
+	case PriorityInteractive:
+		return "jobs:pending:p0"
+	case PriorityBatch:
+		return "jobs:pending:p2"
+	default:
+		return "jobs:pending:p1"
+	}
+}
+
+func activeCounterKeyFor(p Priority) string {
+	return fmt.Sprintf("jobs:active:%s", p)
+}
+
+func processingKey(workerID string) string {
+	return fmt.Sprintf("jobs:processing:%s", workerID)
+}
+
+// EnqueuePending pushes jobID onto priority's pending queue, visible to
+// every worker replica.
+func (s *Storage) EnqueuePending(jobID string, priority Priority) error {
+	return s.rdb.LPush(s.ctx, pendingQueueKeyFor(priority), jobID).Err()
+}
+
+// QueueLen returns the number of jobs currently waiting in priority's
+// queue, across the whole fleet.
+func (s *Storage) QueueLen(priority Priority) (int64, error) {
+	return s.rdb.LLen(s.ctx, pendingQueueKeyFor(priority)).Result()
+}
+
+// ActiveCount returns the number of jobs of priority currently being
+// processed, across the whole fleet.
+func (s *Storage) ActiveCount(priority Priority) (int64, error) {
+	val, err := s.rdb.Get(s.ctx, activeCounterKeyFor(priority)).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return val, err
+}
+
+func (s *Storage) IncrActive(priority Priority) error {
+	return s.rdb.Incr(s.ctx, activeCounterKeyFor(priority)).Err()
+}
+
+func (s *Storage) DecrActive(priority Priority) error {
+	return s.rdb.Decr(s.ctx, activeCounterKeyFor(priority)).Err()
+}
+
+// DequeueNonBlocking tries to atomically move one job from priority's
+// pending queue onto workerID's processing list, returning "" (no
+// error) if that queue is currently empty.
+func (s *Storage) DequeueNonBlocking(workerID string, priority Priority) (string, error) {
+	result, err := s.rdb.RPopLPush(s.ctx, pendingQueueKeyFor(priority), processingKey(workerID)).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return result, err
+}
+
+// Dequeue blocks up to timeout for a pending job in priority's queue,
+// atomically moving it onto workerID's processing list so it can be
+// recovered if this worker dies before acking it. It returns "" (no
+// error) on timeout.
+func (s *Storage) Dequeue(workerID string, priority Priority, timeout time.Duration) (string, error) {
+	result, err := s.rdb.BRPopLPush(s.ctx, pendingQueueKeyFor(priority), processingKey(workerID), timeout).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return result, err
+}
+
+// Ack removes jobID from workerID's processing list once it has reached
+// a terminal state.
+func (s *Storage) Ack(workerID, jobID string) error {
+	return s.rdb.LRem(s.ctx, processingKey(workerID), 1, jobID).Err()
+}
+
+// RecoverOrphaned requeues every job left in workerID's processing list
+// (e.g. from a crash before the previous process could ack them) back
+// onto its original priority's pending queue, returning how many it
+// recovered. Each recovered job also has its priority's active counter
+// decremented: the crashed process incremented it via IncrActive before
+// dying without ever reaching the matching DecrActive, so without this
+// the counter permanently overcounts by one per crash, eventually
+// convincing Enqueue's admission check every priority class is full.
+func (s *Storage) RecoverOrphaned(workerID string) (int, error) {
+	key := processingKey(workerID)
+	count := 0
+	for {
+		jobID, err := s.rdb.RPop(s.ctx, key).Result()
+		if err == redis.Nil {
+			break
+		}
+		if err != nil {
+			return count, err
+		}
+
+		priority := PriorityNormal
+		if meta, metaErr := s.GetJobMeta(jobID); metaErr == nil {
+			priority = normalizePriority(meta.Priority)
+		}
+		if err := s.DecrActive(priority); err != nil {
+			return count, err
+		}
+		if err := s.EnqueuePending(jobID, priority); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+const deadLetterKey = "jobs:dead"
+
+// RecordAttempt appends a failure record to job:{id}:attempts and
+// returns the number of attempts recorded so far.
+func (s *Storage) RecordAttempt(jobID string, attemptErr string) (int, error) {
+	key := fmt.Sprintf("job:%s:attempts", jobID)
+	entry, _ := json.Marshal(map[string]string{
+		"error": attemptErr,
+		"at":    time.Now().Format(time.RFC3339),
+	})
+	if err := s.rdb.RPush(s.ctx, key, string(entry)).Err(); err != nil {
+		return 0, err
+	}
+	n, err := s.rdb.LLen(s.ctx, key).Result()
+	return int(n), err
+}
+
+// UpdateAttemptMeta records the current attempt count and the
+// last/next attempt timestamps on the job's meta hash.
+func (s *Storage) UpdateAttemptMeta(jobID string, attempts int, lastAttemptAt, nextAttemptAt string) error {
+	key := fmt.Sprintf("job:%s:meta", jobID)
+	data := map[string]interface{}{
+		"attempts": attempts,
+	}
+	if lastAttemptAt != "" {
+		data["last_attempt_at"] = lastAttemptAt
+	}
+	if nextAttemptAt != "" {
+		data["next_attempt_at"] = nextAttemptAt
+	}
+	return s.rdb.HSet(s.ctx, key, data).Err()
+}
+
+// HasChunks reports whether any chunks have already been persisted for
+// jobID, used to decide whether a retry would duplicate partial output.
+func (s *Storage) HasChunks(jobID string) (bool, error) {
+	key := fmt.Sprintf("job:%s:chunks", jobID)
+	n, err := s.rdb.LLen(s.ctx, key).Result()
+	return n > 0, err
+}
+
+// MoveToDeadLetter records jobID as having exhausted its retry budget.
+func (s *Storage) MoveToDeadLetter(jobID string) error {
+	return s.rdb.RPush(s.ctx, deadLetterKey, jobID).Err()
+}
+
+// DeadLetterJobIDs lists every job currently in the dead-letter queue.
+func (s *Storage) DeadLetterJobIDs() ([]string, error) {
+	return s.rdb.LRange(s.ctx, deadLetterKey, 0, -1).Result()
+}
+
+// RemoveFromDeadLetter takes jobID out of the dead-letter queue, e.g.
+// when an operator manually requeues it.
+func (s *Storage) RemoveFromDeadLetter(jobID string) error {
+	return s.rdb.LRem(s.ctx, deadLetterKey, 1, jobID).Err()
+}
+
+// scheduledSetKey holds jobs awaiting a future run_at, scored by the unix
+// timestamp they become due.
+const scheduledSetKey = "jobs:scheduled"
+
+// claimDueScheduledScript atomically claims every due job off
+// jobs:scheduled. The ZREM-before-return pattern means only one
+// replica's call can successfully claim a given job: a concurrent run on
+// another replica sees ZREM return 0 for it and skips it, so a job is
+// never claimed twice. It deliberately does no more than that: the
+// caller still has to route each claimed job through the same
+// priority-limit admission check (Worker.Enqueue) that immediately
+// created jobs go through, rather than pushing straight onto the
+// pending queue.
+var claimDueScheduledScript = redis.NewScript(`
+local due = redis.call('ZRANGEBYSCORE', KEYS[1], '0', ARGV[1], 'LIMIT', 0, tonumber(ARGV[2]))
+local claimed = {}
+for _, jobID in ipairs(due) do
+	if redis.call('ZREM', KEYS[1], jobID) == 1 then
+		table.insert(claimed, jobID)
+	end
+end
+return claimed
+`)
+
+// ScheduleJob defers jobID's dispatch until runAt by parking it in
+// jobs:scheduled instead of a pending queue; the worker's scheduler loop
+// claims it once due.
+func (s *Storage) ScheduleJob(jobID string, runAt time.Time) error {
+	return s.rdb.ZAdd(s.ctx, scheduledSetKey, &redis.Z{Score: float64(runAt.Unix()), Member: jobID}).Err()
+}
+
+// ClaimDueScheduledJobs claims up to limit jobs whose run_at has passed,
+// removing them from jobs:scheduled and returning their IDs. Safe to
+// call concurrently from every worker replica; claimed jobs are the
+// caller's responsibility to dispatch (or re-schedule).
+func (s *Storage) ClaimDueScheduledJobs(now time.Time, limit int) ([]string, error) {
+	result, err := claimDueScheduledScript.Run(s.ctx, s.rdb, []string{scheduledSetKey}, now.Unix(), limit).StringSlice()
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// CancelScheduled removes jobID from jobs:scheduled before it becomes
+// due, reporting whether it was actually still there (false means it
+// already ran, or was never scheduled).
+func (s *Storage) CancelScheduled(jobID string) (bool, error) {
+	removed, err := s.rdb.ZRem(s.ctx, scheduledSetKey, jobID).Result()
+	if err != nil {
+		return false, err
+	}
+	return removed > 0, nil
+}
+
+// Worker pulls jobs from the shared Redis pending queue rather than an
+// in-memory channel, so multiple gateway/worker replicas can share one
+// backlog and a crashed worker's in-flight jobs can be recovered by
+// whoever restarts under the same workerID.
 type Worker struct {
-	storage        *Storage
-	ollamaURL      string
-	concurrency    int
-	maxQueueSize   int
-	queue          chan string
-	wg             sync.WaitGroup
-	mu             sync.RWMutex
-	cancelled      map[string]bool
-	activeJobs     int
-	queuedJobs     int
-}
-
-func NewWorker(storage *Storage, ollamaURL string, concurrency int) *Worker {
-	maxQueueSize := concurrency * 2
+	storage          *Storage
+	ollamaURL        string
+	concurrency      int
+	priorityLimits   map[Priority]int
+	workerID         string
+	maxAttempts      int
+	retryBackoffBase time.Duration
+	wg               sync.WaitGroup
+	mu               sync.RWMutex
+	cancelled        map[string]bool
+	cycleMu          sync.Mutex
+	cyclePos         int
+}
+
+func NewWorker(storage *Storage, ollamaURL string, concurrency int, workerID string, maxAttempts int, retryBackoffBase time.Duration, priorityLimits map[Priority]int) *Worker {
 	w := &Worker{
-		storage:      storage,
-		ollamaURL:    ollamaURL,
-		concurrency:  concurrency,
-		maxQueueSize: maxQueueSize,
-		queue:        make(chan string, maxQueueSize),
-		cancelled:    make(map[string]bool),
+		storage:          storage,
+		ollamaURL:        ollamaURL,
+		concurrency:      concurrency,
+		priorityLimits:   priorityLimits,
+		workerID:         workerID,
+		maxAttempts:      maxAttempts,
+		retryBackoffBase: retryBackoffBase,
+		cancelled:        make(map[string]bool),
+	}
+
+	if n, err := storage.RecoverOrphaned(workerID); err != nil {
+		log.Printf("Failed to recover orphaned jobs for worker %s: %v", workerID, err)
+	} else if n > 0 {
+		log.Printf("Recovered %d orphaned job(s) for worker %s", n, workerID)
 	}
 
 	for i := 0; i < concurrency; i++ {
@@ -236,39 +703,101 @@ func NewWorker(storage *Storage, ollamaURL string, concurrency int) *Worker {
 		go w.run()
 	}
 
+	go w.schedulerLoop()
+
 	return w
 }
 
-func (w *Worker) Enqueue(jobID string) error {
-	w.mu.Lock()
-	currentActive := w.activeJobs
-	currentQueued := len(w.queue)
-	w.mu.Unlock()
+// schedulerLoop polls jobs:scheduled once a second and dispatches anything
+// due. Every worker replica runs this loop; Storage.ClaimDueScheduledJobs
+// is safe to call concurrently from all of them.
+func (w *Worker) schedulerLoop() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
 
-	totalLoad := currentActive + currentQueued
-	if totalLoad >= w.maxQueueSize {
-		return fmt.Errorf("queue full: active=%d, queued=%d, max=%d", currentActive, currentQueued, w.maxQueueSize)
+	for range ticker.C {
+		jobIDs, err := w.storage.ClaimDueScheduledJobs(time.Now(), 100)
+		if err != nil {
+			log.Printf("Failed to claim due scheduled jobs: %v", err)
+			continue
+		}
+		for _, jobID := range jobIDs {
+			w.dispatchDueJob(jobID)
+		}
 	}
+}
 
-	select {
-	case w.queue <- jobID:
-		w.mu.Lock()
-		w.queuedJobs++
-		w.mu.Unlock()
-		return nil
-	default:
-		return fmt.Errorf("queue channel full")
+// dispatchDueJob routes a claimed scheduled (or backoff-retry) job
+// through the same priority-limit admission check, Worker.Enqueue, that
+// an immediately-created job goes through. If that priority class is
+// currently at capacity, the job is re-parked in jobs:scheduled for a
+// quick re-check rather than dropped or pushed past the limit.
+func (w *Worker) dispatchDueJob(jobID string) {
+	meta, err := w.storage.GetJobMeta(jobID)
+	if err != nil {
+		log.Printf("Failed to load meta for due job %s: %v", jobID, err)
+		return
+	}
+	priority := normalizePriority(meta.Priority)
+
+	if err := w.Enqueue(jobID, priority); err != nil {
+		log.Printf("Due job %s deferred, %s queue at capacity: %v", jobID, priority, err)
+		if schedErr := w.storage.ScheduleJob(jobID, time.Now().Add(time.Second)); schedErr != nil {
+			log.Printf("Failed to re-schedule deferred job %s: %v", jobID, schedErr)
+		}
+		return
+	}
+
+	if err := w.storage.UpdateJobStatus(jobID, StatusQueued, "", ""); err != nil {
+		log.Printf("Failed to mark due job %s queued: %v", jobID, err)
 	}
 }
 
-func (w *Worker) GetStats() map[string]int {
-	w.mu.RLock()
-	defer w.mu.RUnlock()
-	return map[string]int{
-		"active":   w.activeJobs,
-		"queued":   len(w.queue),
-		"capacity": w.concurrency,
-		"max_queue": w.maxQueueSize,
+func (w *Worker) Enqueue(jobID string, priority Priority) error {
+	limit := w.priorityLimits[priority]
+	if limit <= 0 {
+		limit = w.concurrency * 2
+	}
+
+	active, err := w.storage.ActiveCount(priority)
+	if err != nil {
+		return err
+	}
+	queued, err := w.storage.QueueLen(priority)
+	if err != nil {
+		return err
+	}
+
+	if int(active)+int(queued) >= limit {
+		return fmt.Errorf("%s queue full: active=%d, queued=%d, max=%d", priority, active, queued, limit)
+	}
+
+	return w.storage.EnqueuePending(jobID, priority)
+}
+
+// GetStats samples the live Redis counters, so the numbers reflect the
+// whole fleet of worker replicas, not just this process, broken down by
+// priority class.
+func (w *Worker) GetStats() map[string]interface{} {
+	priorities := make(map[string]interface{}, len(priorityOrder))
+	totalActive, totalQueued := 0, 0
+	for _, p := range priorityOrder {
+		active, _ := w.storage.ActiveCount(p)
+		queued, _ := w.storage.QueueLen(p)
+		totalActive += int(active)
+		totalQueued += int(queued)
+		priorities[string(p)] = map[string]int{
+			"active": int(active),
+			"queued": int(queued),
+			"max":    w.priorityLimits[p],
+		}
+	}
+
+	return map[string]interface{}{
+		"active":     totalActive,
+		"queued":     totalQueued,
+		"capacity":   w.concurrency,
+		"priorities": priorities,
 	}
 }
 
@@ -284,22 +813,90 @@ func (w *Worker) isCancelled(jobID string) bool {
 	return w.cancelled[jobID]
 }
 
+// nextPriority advances the 8:4:1 weighted round-robin and returns the
+// priority class due to be polled.
+func (w *Worker) nextPriority() Priority {
+	w.cycleMu.Lock()
+	p := weightedPrioritySequence[w.cyclePos%len(weightedPrioritySequence)]
+	w.cyclePos++
+	w.cycleMu.Unlock()
+	return p
+}
+
+// dequeueOnce tries the weighted round-robin's preferred priority first
+// (non-blocking), then scans every priority so a quiet low-priority
+// queue isn't starved indefinitely, finally blocking briefly on the
+// preferred queue so idle workers don't busy-loop.
+func (w *Worker) dequeueOnce() (string, Priority, error) {
+	preferred := w.nextPriority()
+
+	if jobID, err := w.storage.DequeueNonBlocking(w.workerID, preferred); err != nil {
+		return "", preferred, err
+	} else if jobID != "" {
+		return jobID, preferred, nil
+	}
+
+	for _, p := range priorityOrder {
+		jobID, err := w.storage.DequeueNonBlocking(w.workerID, p)
+		if err != nil {
+			return "", p, err
+		}
+		if jobID != "" {
+			return jobID, p, nil
+		}
+	}
+
+	jobID, err := w.storage.Dequeue(w.workerID, preferred, time.Second)
+	return jobID, preferred, err
+}
+
 func (w *Worker) run() {
 	defer w.wg.Done()
-	for jobID := range w.queue {
-		w.mu.Lock()
-		w.activeJobs++
-		w.queuedJobs--
-		w.mu.Unlock()
-
-		w.processJob(jobID)
+	for {
+		jobID, priority, err := w.dequeueOnce()
+		if err != nil {
+			log.Printf("Failed to dequeue job: %v", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		if jobID == "" {
+			continue
+		}
 
-		w.mu.Lock()
-		w.activeJobs--
-		w.mu.Unlock()
+		w.runOne(jobID, priority)
 	}
 }
 
+// runOne processes one job with DecrActive and Ack deferred so a panic
+// anywhere in processJob still releases that priority's active-counter
+// slot and the processing-list entry, instead of leaking both and
+// eventually starving Enqueue's admission check.
+func (w *Worker) runOne(jobID string, priority Priority) {
+	w.storage.IncrActive(priority)
+	defer func() {
+		w.storage.DecrActive(priority)
+		if r := recover(); r != nil {
+			log.Printf("Recovered from panic processing job %s: %v", jobID, r)
+		}
+		if err := w.storage.Ack(w.workerID, jobID); err != nil {
+			log.Printf("Failed to ack job %s for worker %s: %v", jobID, w.workerID, err)
+		}
+	}()
+
+	w.processJob(jobID)
+}
+
+// jobError carries both a failure's message and whether it's worth
+// retrying: transient backend/network trouble is retryable, but
+// client-caused failures (bad model, 4xx, a local marshal/request-build
+// error) never are.
+type jobError struct {
+	message   string
+	retryable bool
+}
+
+func (e *jobError) Error() string { return e.message }
+
 func (w *Worker) processJob(jobID string) {
 	meta, err := w.storage.GetJobMeta(jobID)
 	if err != nil {
@@ -312,8 +909,27 @@ func (w *Worker) processJob(jobID string) {
 		return
 	}
 
-	messages, _ := w.storage.rdb.HGet(context.Background(), fmt.Sprintf("job:%s:meta", jobID), "messages").Result()
-	options, _ := w.storage.rdb.HGet(context.Background(), fmt.Sprintf("job:%s:meta", jobID), "options").Result()
+	if jobErr := w.runJob(jobID, meta); jobErr != nil {
+		w.handleJobFailure(jobID, meta, jobErr)
+		return
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	if err := w.storage.UpdateJobStatus(jobID, StatusCompleted, now, ""); err != nil {
+		log.Printf("Failed to complete job %s: %v", jobID, err)
+	}
+
+	ttl := 24 * time.Hour
+	if err := w.storage.SetTTL(jobID, ttl); err != nil {
+		log.Printf("Failed to set TTL for job %s: %v", jobID, err)
+	}
+}
+
+// runJob drives a single attempt at jobID against Ollama, returning a
+// *jobError (tagged retryable or not) on any failure, or nil on success.
+func (w *Worker) runJob(jobID string, meta *JobMeta) *jobError {
+	messages, _ := w.storage.LoadLargeField(jobID, "messages")
+	options, _ := w.storage.LoadLargeField(jobID, "options")
 
 	reqBody := map[string]interface{}{
 		"model":  meta.Model,
@@ -338,28 +954,26 @@ func (w *Worker) processJob(jobID string) {
 
 	payload, err := json.Marshal(reqBody)
 	if err != nil {
-		w.finishJobWithError(jobID, fmt.Sprintf("marshal error: %v", err))
-		return
+		return &jobError{message: fmt.Sprintf("marshal error: %v", err)}
 	}
 
 	req, err := http.NewRequest("POST", w.ollamaURL+"/v1/chat/completions", bytes.NewReader(payload))
 	if err != nil {
-		w.finishJobWithError(jobID, fmt.Sprintf("create request error: %v", err))
-		return
+		return &jobError{message: fmt.Sprintf("create request error: %v", err)}
 	}
 	req.Header.Set("Content-Type", "application/json")
 
 	client := &http.Client{Timeout: 0}
 	resp, err := client.Do(req)
 	if err != nil {
-		w.finishJobWithError(jobID, fmt.Sprintf("ollama request error: %v", err))
-		return
+		return &jobError{message: fmt.Sprintf("ollama request error: %v", err), retryable: true}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		w.finishJobWithError(jobID, fmt.Sprintf("ollama status: %d", resp.StatusCode))
-		return
+		// 4xx means the request itself is bad (unknown model, malformed
+		// payload); retrying it would just fail again the same way.
+		return &jobError{message: fmt.Sprintf("ollama status: %d", resp.StatusCode), retryable: resp.StatusCode >= 500}
 	}
 
 	scanner := bufio.NewScanner(resp.Body)
@@ -367,11 +981,11 @@ func (w *Worker) processJob(jobID string) {
 	buf := make([]byte, 0, 1024*64)
 	scanner.Buffer(buf, 1024*1024)
 	finishReason := "stop"
+	sawDone := false
 
 	for scanner.Scan() {
 		if w.isCancelled(jobID) {
-			w.finishJobWithError(jobID, "cancelled")
-			return
+			return &jobError{message: "cancelled"}
 		}
 
 		line := strings.TrimSpace(scanner.Text())
@@ -385,8 +999,7 @@ func (w *Worker) processJob(jobID string) {
 
 		var streamResp OllamaStreamResponse
 		if err := json.Unmarshal([]byte(payload), &streamResp); err != nil {
-			w.finishJobWithError(jobID, fmt.Sprintf("decode error: %v", err))
-			return
+			return &jobError{message: fmt.Sprintf("decode error: %v", err), retryable: true}
 		}
 
 		seq, err := w.storage.IncrSeq(jobID)
@@ -413,26 +1026,82 @@ func (w *Worker) processJob(jobID string) {
 		}
 
 		if streamResp.Done {
+			sawDone = true
 			break
 		}
 	}
 
 	if err := scanner.Err(); err != nil && err != io.EOF {
-		w.finishJobWithError(jobID, fmt.Sprintf("stream error: %v", err))
+		return &jobError{message: fmt.Sprintf("stream error: %v", err), retryable: true}
+	}
+
+	if !sawDone {
+		return &jobError{message: "stream ended before completion", retryable: true}
+	}
+
+	return nil
+}
+
+// handleJobFailure decides, for a failed attempt, whether to retry
+// jobID after a backoff, dead-letter it once attempts are exhausted, or
+// fail it outright for a non-retryable error.
+func (w *Worker) handleJobFailure(jobID string, meta *JobMeta, jobErr *jobError) {
+	if !jobErr.retryable {
+		w.finishJobWithError(jobID, jobErr.message)
 		return
 	}
 
-	now := time.Now().Format(time.RFC3339)
-	if err := w.storage.UpdateJobStatus(jobID, StatusCompleted, now, ""); err != nil {
-		log.Printf("Failed to complete job %s: %v", jobID, err)
+	hasChunks, err := w.storage.HasChunks(jobID)
+	if err != nil {
+		log.Printf("Failed to check chunks for job %s: %v", jobID, err)
+	}
+	if hasChunks && !meta.AllowPartialRetry {
+		w.finishJobWithError(jobID, jobErr.message)
+		return
 	}
 
-	ttl := 24 * time.Hour
-	if err := w.storage.SetTTL(jobID, ttl); err != nil {
-		log.Printf("Failed to set TTL for job %s: %v", jobID, err)
+	attempts, err := w.storage.RecordAttempt(jobID, jobErr.message)
+	if err != nil {
+		log.Printf("Failed to record attempt for job %s: %v", jobID, err)
+	}
+
+	if attempts >= w.maxAttempts {
+		w.finishJobWithError(jobID, fmt.Sprintf("exhausted %d attempts: %s", attempts, jobErr.message))
+		if err := w.storage.MoveToDeadLetter(jobID); err != nil {
+			log.Printf("Failed to dead-letter job %s: %v", jobID, err)
+		}
+		return
+	}
+
+	backoff := w.retryBackoffFor(attempts)
+	now := time.Now()
+	nextAttemptAt := now.Add(backoff)
+	if err := w.storage.UpdateAttemptMeta(jobID, attempts, now.Format(time.RFC3339), nextAttemptAt.Format(time.RFC3339)); err != nil {
+		log.Printf("Failed to update attempt meta for job %s: %v", jobID, err)
+	}
+	if err := w.storage.UpdateJobStatus(jobID, StatusQueued, "", ""); err != nil {
+		log.Printf("Failed to requeue job %s: %v", jobID, err)
+	}
+
+	// Park the retry in jobs:scheduled (the same durable ZSET chunk1-5
+	// uses for run_at) instead of a bare in-process goroutine, so a crash
+	// or restart during the backoff window doesn't lose the job: the
+	// scheduler loop on every replica will pick it up once due.
+	if err := w.storage.ScheduleJob(jobID, nextAttemptAt); err != nil {
+		log.Printf("Failed to schedule retry for job %s: %v", jobID, err)
 	}
 }
 
+// retryBackoffFor returns the exponential backoff to wait before retry
+// number attempt+1 (attempt is the count of attempts already recorded).
+func (w *Worker) retryBackoffFor(attempt int) time.Duration {
+	backoff := w.retryBackoffBase
+	for i := 1; i < attempt; i++ {
+		backoff *= 2
+	}
+	return backoff
+}
+
 func (w *Worker) finishJobWithError(jobID string, errorMsg string) {
 	seq, _ := w.storage.IncrSeq(jobID)
 	chunk := ChunkData{
@@ -459,16 +1128,39 @@ func (s *Server) CreateJob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	stats := s.worker.GetStats()
-	log.Printf("Worker stats before enqueue: %+v", stats)
+	var runAt time.Time
+	if req.RunAt != "" {
+		parsed, err := time.Parse(time.RFC3339, req.RunAt)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid run_at: %v", err), http.StatusBadRequest)
+			return
+		}
+		runAt = parsed
+	}
+	scheduled := req.RunAt != "" && runAt.After(time.Now())
+
+	if !scheduled {
+		stats := s.worker.GetStats()
+		log.Printf("Worker stats before enqueue: %+v", stats)
+	}
 
 	jobID := uuid.New().String()
 	now := time.Now().Format(time.RFC3339)
 
+	priority := normalizePriority(req.Priority)
+	status := StatusQueued
+	if scheduled {
+		status = StatusScheduled
+	}
 	meta := JobMeta{
-		Status:    StatusQueued,
-		Model:     req.Model,
-		CreatedAt: now,
+		Status:            status,
+		Model:             req.Model,
+		CreatedAt:         now,
+		AllowPartialRetry: req.AllowPartialRetry,
+		Priority:          string(priority),
+	}
+	if scheduled {
+		meta.ScheduledAt = req.RunAt
 	}
 
 	if err := s.storage.CreateJob(jobID, meta); err != nil {
@@ -478,17 +1170,29 @@ func (s *Server) CreateJob(w http.ResponseWriter, r *http.Request) {
 
 	if len(req.Messages) > 0 {
 		data, _ := json.Marshal(req.Messages)
-		s.storage.rdb.HSet(context.Background(), fmt.Sprintf("job:%s:meta", jobID), "messages", string(data))
+		s.storage.StoreLargeField(jobID, "messages", data)
 	}
 
 	if len(req.Options) > 0 {
 		data, _ := json.Marshal(req.Options)
-		s.storage.rdb.HSet(context.Background(), fmt.Sprintf("job:%s:meta", jobID), "options", string(data))
+		s.storage.StoreLargeField(jobID, "options", data)
+	}
+
+	if scheduled {
+		if err := s.storage.ScheduleJob(jobID, runAt); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		resp := JobResponse{JobID: jobID, Status: StatusScheduled}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+		return
 	}
 
-	if err := s.worker.Enqueue(jobID); err != nil {
+	if err := s.worker.Enqueue(jobID, priority); err != nil {
 		s.storage.UpdateJobStatus(jobID, StatusFailed, now, err.Error())
-		
+
 		errorResp := map[string]interface{}{
 			"error": map[string]interface{}{
 				"message": fmt.Sprintf("Service overloaded: %v", err),
@@ -541,6 +1245,100 @@ func (s *Server) GetEvents(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(resp)
 }
 
+// StreamJob serves GET /jobs/{job_id}/stream: it replays any chunks
+// already persisted from fromSeq (taken from the Last-Event-ID header,
+// so a reconnecting client resumes where it left off, or a from_seq
+// query param), then subscribes to the job's Redis pub/sub channel for
+// new ones. It closes once a done chunk or a terminal job status is
+// seen. The polling GetEvents endpoint is left in place alongside this.
+func (s *Server) StreamJob(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	jobID := vars["job_id"]
+
+	fromSeq := -1
+	if lastID := r.Header.Get("Last-Event-ID"); lastID != "" {
+		if seq, err := strconv.Atoi(lastID); err == nil {
+			fromSeq = seq
+		}
+	} else if seqStr := r.URL.Query().Get("from_seq"); seqStr != "" {
+		if seq, err := strconv.Atoi(seqStr); err == nil {
+			fromSeq = seq
+		}
+	}
+
+	if _, err := s.storage.GetJobMeta(jobID); err != nil {
+		http.Error(w, "job not found", http.StatusNotFound)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	lastSeq := fromSeq
+	writeChunk := func(chunk ChunkData) bool {
+		data, err := json.Marshal(chunk)
+		if err != nil {
+			return false
+		}
+		if _, err := fmt.Fprintf(w, "id: %d\ndata: %s\n\n", chunk.Seq, data); err != nil {
+			return false
+		}
+		flusher.Flush()
+		lastSeq = chunk.Seq
+		return !chunk.Done
+	}
+
+	// Subscribe before replaying persisted chunks: anything published in
+	// the gap between the replay snapshot and subscribing would otherwise
+	// be seen by neither (and if it's the terminal chunk, the client
+	// would hang until its own timeout). Once subscribed, any such chunk
+	// sits buffered on the channel until the loop below drains it;
+	// chunk.Seq <= lastSeq dedupes it if the replay already picked it up
+	// too.
+	sub := s.storage.SubscribeChunks(jobID)
+	defer sub.Close()
+	ch := sub.Channel()
+
+	chunks, err := s.storage.GetChunks(jobID, fromSeq)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, chunk := range chunks {
+		if !writeChunk(chunk) {
+			return
+		}
+	}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			var chunk ChunkData
+			if err := json.Unmarshal([]byte(msg.Payload), &chunk); err != nil {
+				continue
+			}
+			if chunk.Seq <= lastSeq {
+				continue
+			}
+			if !writeChunk(chunk) {
+				return
+			}
+		}
+	}
+}
+
 func (s *Server) GetStatus(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	jobID := vars["job_id"]
@@ -573,15 +1371,136 @@ func (s *Server) CancelJob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Push a terminal chunk immediately so anyone subscribed via
+	// StreamJob unblocks right away, instead of waiting for the worker
+	// to next check isCancelled.
+	if seq, err := s.storage.IncrSeq(jobID); err == nil {
+		s.storage.AddChunk(jobID, ChunkData{Seq: seq, Done: true, FinishReason: "cancelled"})
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// DeleteJob handles DELETE /jobs/{job_id}: it cancels a job that is still
+// sitting in jobs:scheduled, before any worker has picked it up. It's
+// distinct from CancelJob, which only affects a job already dispatched
+// and running; a job that has left jobs:scheduled (or was never
+// scheduled) is not touched here.
+func (s *Server) DeleteJob(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	jobID := vars["job_id"]
+
+	removed, err := s.storage.CancelScheduled(jobID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !removed {
+		http.Error(w, "job is not scheduled (already dispatched or not found)", http.StatusConflict)
+		return
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	if err := s.storage.UpdateJobStatus(jobID, StatusCancelled, now, ""); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	w.WriteHeader(http.StatusOK)
 }
 
 func (s *Server) GetWorkerStats(w http.ResponseWriter, r *http.Request) {
 	stats := s.worker.GetStats()
+	stats["compression"] = s.storage.compressionStats.snapshot()
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(stats)
 }
 
+// GetDeadJobs lists every job that exhausted its retry attempts, for
+// operator triage.
+func (s *Server) GetDeadJobs(w http.ResponseWriter, r *http.Request) {
+	ids, err := s.storage.DeadLetterJobIDs()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jobs := make([]map[string]interface{}, 0, len(ids))
+	for _, id := range ids {
+		meta, err := s.storage.GetJobMeta(id)
+		if err != nil {
+			continue
+		}
+		jobs = append(jobs, map[string]interface{}{
+			"job_id":   id,
+			"status":   meta.Status,
+			"model":    meta.Model,
+			"error":    meta.Error,
+			"attempts": meta.Attempts,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"jobs": jobs})
+}
+
+// RequeueJob manually retries a dead-lettered job: it's taken off
+// jobs:dead and pushed back onto the pending queue.
+func (s *Server) RequeueJob(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	jobID := vars["job_id"]
+
+	meta, err := s.storage.GetJobMeta(jobID)
+	if err != nil {
+		http.Error(w, "job not found", http.StatusNotFound)
+		return
+	}
+
+	if err := s.storage.RemoveFromDeadLetter(jobID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.storage.UpdateJobStatus(jobID, StatusQueued, "", ""); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.worker.Enqueue(jobID, normalizePriority(meta.Priority)); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// parsePriorityLimits parses WORKER_PRIORITY_LIMITS, formatted as
+// "interactive=6,normal=3,batch=1". Priorities not mentioned fall back
+// to defaultLimit.
+func parsePriorityLimits(raw string, defaultLimit int) map[Priority]int {
+	limits := map[Priority]int{
+		PriorityInteractive: defaultLimit,
+		PriorityNormal:      defaultLimit,
+		PriorityBatch:       defaultLimit,
+	}
+	if raw == "" {
+		return limits
+	}
+
+	for _, pair := range strings.Split(raw, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		val, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil {
+			continue
+		}
+		limits[normalizePriority(strings.TrimSpace(parts[0]))] = val
+	}
+	return limits
+}
+
 func main() {
 	redisURL := os.Getenv("REDIS_URL")
 	if redisURL == "" {
@@ -600,19 +1519,55 @@ func main() {
 		}
 	}
 
-	storage, err := NewStorage(redisURL)
+	workerID := os.Getenv("WORKER_ID")
+	if workerID == "" {
+		if hostname, err := os.Hostname(); err == nil && hostname != "" {
+			workerID = hostname
+		} else {
+			workerID = uuid.New().String()
+		}
+	}
+
+	maxAttempts := 3
+	if a := os.Getenv("MAX_ATTEMPTS"); a != "" {
+		if val, err := strconv.Atoi(a); err == nil {
+			maxAttempts = val
+		}
+	}
+
+	retryBackoff := 3 * time.Second
+	if b := os.Getenv("RETRY_BACKOFF_MS"); b != "" {
+		if val, err := strconv.Atoi(b); err == nil {
+			retryBackoff = time.Duration(val) * time.Millisecond
+		}
+	}
+
+	priorityLimits := parsePriorityLimits(os.Getenv("WORKER_PRIORITY_LIMITS"), concurrency*2)
+
+	compressionThreshold := defaultCompressionThreshold
+	if t := os.Getenv("COMPRESSION_THRESHOLD"); t != "" {
+		if val, err := strconv.Atoi(t); err == nil {
+			compressionThreshold = val
+		}
+	}
+
+	storage, err := NewStorage(redisURL, compressionThreshold)
 	if err != nil {
 		log.Fatalf("Failed to connect to Redis: %v", err)
 	}
 
-	worker := NewWorker(storage, ollamaURL, concurrency)
+	worker := NewWorker(storage, ollamaURL, concurrency, workerID, maxAttempts, retryBackoff, priorityLimits)
 	server := &Server{storage: storage, worker: worker}
 
 	r := mux.NewRouter()
 	r.HandleFunc("/jobs", server.CreateJob).Methods("POST")
+	r.HandleFunc("/jobs/{job_id}", server.DeleteJob).Methods("DELETE")
 	r.HandleFunc("/jobs/{job_id}/events", server.GetEvents).Methods("GET")
+	r.HandleFunc("/jobs/{job_id}/stream", server.StreamJob).Methods("GET")
 	r.HandleFunc("/jobs/{job_id}/status", server.GetStatus).Methods("GET")
 	r.HandleFunc("/jobs/{job_id}/cancel", server.CancelJob).Methods("POST")
+	r.HandleFunc("/jobs/dead", server.GetDeadJobs).Methods("GET")
+	r.HandleFunc("/jobs/{job_id}/requeue", server.RequeueJob).Methods("POST")
 	r.HandleFunc("/stats", server.GetWorkerStats).Methods("GET")
 
 	port := os.Getenv("PORT")